@@ -111,7 +111,7 @@ func input(conn *mynet.Connection) {
 
 func main() {
 
-	n := mynet.NewSimpleNet()
+	n := mynet.NewSimpleNet(nil)
 
 	c, e := n.Connect("127.0.0.1:3369", nil)
 	if e != nil {
@@ -1,69 +1,62 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
-
+	"os/signal"
 	"time"
 
 	mynet "github.com/buf1024/golib/net"
 )
 
-func server(listen *mynet.Listener) {
-	fmt.Printf("server listenning %s\n", listen.LocalAddress())
-	n := listen.Net()
-	for {
-		evt, err := n.PollEvent(1000 * 60)
-		if err != nil {
-			fmt.Printf("poll event error!")
-			return
-		}
-		conn := evt.Conn
-		switch {
-		case evt.EventType == mynet.EventConnectionError:
-			{
-				fmt.Printf("event error: local = %s, remote = %s\n",
-					conn.LocalAddress(), conn.RemoteAddress())
-			}
-		case evt.EventType == mynet.EventConnectionClosed:
-			{
-				fmt.Printf("connection close: local = %s, remote = %s\n",
-					conn.LocalAddress(), conn.RemoteAddress())
-			}
-		case evt.EventType == mynet.EventNewConnectionData:
-			{
-				data := evt.Data.([]byte)
-				fmt.Printf("%s", (string)(data))
-				err = n.SendData(evt.Conn, evt.Data)
-				if err != nil {
-					fmt.Printf("send data error, err = %s\n", err)
-				}
-			}
-		case evt.EventType == mynet.EventNewConnection:
-			{
-				fmt.Printf("client conneced: local = %s, remote = %s\n",
-					conn.LocalAddress(), conn.RemoteAddress())
-			}
-		case evt.EventType == mynet.EventTimeout:
-			{
-				fmt.Printf("poll timeout now = %d\n", time.Now().Unix())
-			}
-		}
+type echoHandler struct {
+	mynet.NopHandler
+}
+
+func (h *echoHandler) OnConnect(conn *mynet.Connection) {
+	fmt.Printf("client conneced: local = %s, remote = %s\n",
+		conn.LocalAddress(), conn.RemoteAddress())
+}
+
+func (h *echoHandler) OnData(conn *mynet.Connection, data interface{}) {
+	fmt.Printf("%s", (string)(data.([]byte)))
+	err := conn.Net().SendData(conn, data)
+	if err != nil {
+		fmt.Printf("send data error, err = %s\n", err)
 	}
 }
 
+func (h *echoHandler) OnClose(conn *mynet.Connection, err error) {
+	fmt.Printf("connection close: local = %s, remote = %s\n",
+		conn.LocalAddress(), conn.RemoteAddress())
+}
+
+func (h *echoHandler) OnError(conn *mynet.Connection, err error) {
+	fmt.Printf("event error: local = %s, remote = %s, err = %s\n",
+		conn.LocalAddress(), conn.RemoteAddress(), err)
+}
+
 func main() {
 
-	n := mynet.NewSimpleNet()
+	n := mynet.NewSimpleNet(nil)
 
-	s, e := n.Listen("127.0.0.1:3369", nil)
+	s, e := n.ListenWithHandler("127.0.0.1:3369", nil, &echoHandler{})
 	if e != nil {
 		fmt.Printf("listen failed, err=%s\n", e)
 		os.Exit(-1)
 	}
+	fmt.Printf("server listenning %s\n", s.LocalAddress())
 
-	server(s)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
 
-	mynet.SimpleNetDestroy(n)
+	// 等待回显数据发送完毕
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := n.Shutdown(ctx); err != nil {
+		fmt.Printf("shutdown err = %s\n", err)
+	}
 
 }
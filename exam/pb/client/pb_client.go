@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -20,47 +21,13 @@ type userData struct {
 }
 
 func handler(conn *mynet.Connection, msg *pb.PbProto) {
-	n := conn.Net()
 	data := conn.UserData.(*userData)
 	pro := data.proto
 
 	fmt.Printf("REQ:\n%s\n", pro.Debug(msg))
 
-	switch msg.H.Command {
-	case pb.CMDHeartBeatReq:
-		{
-			req := msg.B.(*pb.HeartBeatReq)
-
-			rsp := &pb.PbProto{}
-			rsp.H.Command = pb.CMDHeartBeatRsp
-			// beartbeat
-			p, err := pro.GetMessage(rsp.H.Command)
-			if err != nil {
-				fmt.Printf("gen hearbeat rsp failed, err = %s\n", err)
-				return
-			}
-			pheart := p.(*pb.HeartBeatRsp)
-			pheart.SID = proto.String(req.GetSID())
-
-			rsp.B = pheart
-			fmt.Printf("RSP：\n%s\n", pro.Debug(rsp))
-
-			err = n.SendData(conn, rsp)
-
-			if err != nil {
-				fmt.Printf("send hearbeat rsp, err = %s\n", err)
-				return
-			}
-		}
-	case pb.CMDHeartBeatRsp:
-		{
-		}
-	case pb.CMDBizRsp:
-		{
-		}
-	default:
-		fmt.Printf("unknow command 0x%x\n", msg.H.Command)
-	}
+	// 心跳请求由SimpleNet自动应答，业务应答由Call接收
+	fmt.Printf("unknow command 0x%x\n", msg.H.Command)
 }
 
 func clientRcv(conn *mynet.Connection) {
@@ -170,12 +137,14 @@ func clientSend(conn *mynet.Connection) {
 						m.B = pbiz
 
 						fmt.Printf("SEND：\n%s\n", pro.Debug(m))
-						err = n.SendData(conn, m)
-
+						ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+						rsp, err := n.Call(ctx, conn, m)
+						cancel()
 						if err != nil {
-							fmt.Printf("send biz, err = %s\n", err)
+							fmt.Printf("call biz, err = %s\n", err)
 							continue
 						}
+						fmt.Printf("RSP：\n%s\n", pro.Debug(rsp.(*pb.PbProto)))
 					}
 				}
 			}
@@ -206,7 +175,7 @@ func input(conn *mynet.Connection) {
 
 func main() {
 
-	n := mynet.NewSimpleNet()
+	n := mynet.NewSimpleNet(nil)
 
 	data := &userData{
 		w:     &sync.WaitGroup{},
@@ -23,6 +23,8 @@ type PbProto struct {
 }
 
 type PbServerProto struct {
+	// Registry 解析包体使用的命令字注册表，nil时使用DefaultRegistry
+	Registry *Registry
 }
 
 const (
@@ -36,8 +38,6 @@ const (
 	CMDBizRsp       uint64 = 0x00010004
 )
 
-var message map[uint64]proto.Message
-
 func (p *PbServerProto) FilterAccept(conn *mynet.Connection) bool {
 	return true
 }
@@ -46,7 +46,7 @@ func (p *PbServerProto) HeadLen() uint32 {
 }
 func (p *PbServerProto) BodyLen(head []byte) (interface{}, uint32, error) {
 	if (uint32)(len(head)) != constHeadLen {
-		return nil, 0, fmt.Errorf("head size not right")
+		return nil, 0, fmt.Errorf("%w: head size %d", mynet.ErrBadHeader, len(head))
 	}
 	h := &Head{}
 
@@ -90,29 +90,32 @@ func (p *PbServerProto) Parse(head interface{}, body []byte) (interface{}, error
 	return m, nil
 }
 func (p *PbServerProto) Serialize(data interface{}) ([]byte, error) {
+	return p.SerializeTo(nil, data)
+}
+
+// SerializeTo 追加到buf，buf由SimpleNet从缓冲池分配
+func (p *PbServerProto) SerializeTo(buf []byte, data interface{}) ([]byte, error) {
 	m := data.(*PbProto)
 
-	body, err := proto.Marshal(m.B)
-	if err != nil {
-		return nil, err
-	}
-	m.H.Length = (uint32)(len(body))
+	start := len(buf)
+	buf = binary.BigEndian.AppendUint64(buf, m.H.Command)
+	buf = binary.BigEndian.AppendUint32(buf, 0)
+	buf = binary.BigEndian.AppendUint64(buf, m.H.Extral)
 
-	buf := new(bytes.Buffer)
-	if err = binary.Write(buf, binary.BigEndian, m.H.Command); err != nil {
-		return nil, err
-	}
-	if err = binary.Write(buf, binary.BigEndian, m.H.Length); err != nil {
-		return nil, err
-	}
-	if err = binary.Write(buf, binary.BigEndian, m.H.Extral); err != nil {
+	pb := proto.NewBuffer(buf)
+	if err := pb.Marshal(m.B); err != nil {
 		return nil, err
 	}
+	buf = pb.Bytes()
+	m.H.Length = (uint32)(len(buf) - start - (int)(constHeadLen))
+	binary.BigEndian.PutUint32(buf[start+8:], m.H.Length)
 
-	head := buf.Bytes()
-
-	return append(head, body...), nil
+	return buf, nil
+}
 
+// RetainBody proto.Unmarshal会拷贝数据，Parse之后body可以回收
+func (p *PbServerProto) RetainBody() bool {
+	return false
 }
 
 func (p *PbServerProto) Debug(msg *PbProto) string {
@@ -120,12 +123,66 @@ func (p *PbServerProto) Debug(msg *PbProto) string {
 		msg.H.Command, msg.H.Length, msg.H.Extral, proto.CompactTextString(msg.B))
 }
 
+// Heartbeat 心跳请求，由SimpleNet在写空闲时自动发送
+func (p *PbServerProto) Heartbeat(conn *mynet.Connection) (interface{}, error) {
+	m := &PbProto{}
+	m.H.Command = CMDHeartBeatReq
+	msg, err := p.GetMessage(m.H.Command)
+	if err != nil {
+		return nil, err
+	}
+	req := msg.(*HeartBeatReq)
+	req.SID = proto.String(SID(32))
+	m.B = req
+
+	return m, nil
+}
+
+// HeartbeatReply 收到心跳请求回应答，收到心跳应答直接丢弃
+func (p *PbServerProto) HeartbeatReply(conn *mynet.Connection, data interface{}) (interface{}, bool) {
+	m, ok := data.(*PbProto)
+	if !ok {
+		return nil, false
+	}
+	switch m.H.Command {
+	case CMDHeartBeatReq:
+		rsp := &PbProto{}
+		rsp.H.Command = CMDHeartBeatRsp
+		msg, err := p.GetMessage(rsp.H.Command)
+		if err != nil {
+			return nil, true
+		}
+		pheart := msg.(*HeartBeatRsp)
+		pheart.SID = proto.String(m.B.(*HeartBeatReq).GetSID())
+		rsp.B = pheart
+
+		return rsp, true
+	case CMDHeartBeatRsp:
+		return nil, true
+	}
+	return nil, false
+}
+
+// SetCorrelation Call的请求ID放在Head.Extral，应答原样带回
+func (p *PbServerProto) SetCorrelation(req interface{}, id uint64) {
+	req.(*PbProto).H.Extral = id
+}
+
+// Correlation 命令字为偶数的是应答
+func (p *PbServerProto) Correlation(data interface{}) (uint64, bool) {
+	m, ok := data.(*PbProto)
+	if !ok {
+		return 0, false
+	}
+	return m.H.Extral, m.H.Command%2 == 0
+}
+
+// GetMessage 返回命令字对应的新消息，可以并发调用
 func (p *PbServerProto) GetMessage(command uint64) (proto.Message, error) {
-	if m, ok := message[command]; ok {
-		proto.Clone(m)
-		return m, nil
+	if p.Registry != nil {
+		return p.Registry.New(command)
 	}
-	return nil, fmt.Errorf("command %d not found", command)
+	return DefaultRegistry.New(command)
 }
 
 func SID(len int32) string {
@@ -139,14 +196,5 @@ func SID(len int32) string {
 }
 
 func init() {
-
 	rand.NewSource(time.Now().UnixNano())
-
-	message = make(map[uint64]proto.Message)
-
-	message[CMDHeartBeatReq] = &HeartBeatReq{} //0x00010001 // 心跳请求
-	message[CMDHeartBeatRsp] = &HeartBeatRsp{} //0x00010002
-
-	message[CMDBizReq] = &BizReq{} //0x00010003
-	message[CMDBizRsp] = &BizRsp{} //0x00010004
 }
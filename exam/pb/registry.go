@@ -0,0 +1,90 @@
+package pb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	mynet "github.com/buf1024/golib/net"
+	"github.com/golang/protobuf/proto"
+)
+
+// Registry 命令字到消息类型的映射，并发安全
+type Registry struct {
+	lock  sync.RWMutex
+	types map[uint64]reflect.Type
+}
+
+// DefaultRegistry PbServerProto未指定Registry时使用，包含心跳和业务命令字
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry 创建
+func NewRegistry() *Registry {
+	return &Registry{
+		types: make(map[uint64]reflect.Type),
+	}
+}
+
+// Register 注册命令字，prototype只用于确定类型，必须是结构体指针，
+// 命令字重复时返回错误
+func (r *Registry) Register(cmd uint64, prototype proto.Message) error {
+	t := reflect.TypeOf(prototype)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("command 0x%x: prototype should be struct pointer, got %T", cmd, prototype)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if old, ok := r.types[cmd]; ok {
+		return fmt.Errorf("command 0x%x already registered as %s", cmd, old)
+	}
+	r.types[cmd] = t.Elem()
+	return nil
+}
+
+// New 返回命令字对应的新消息，每次调用都是不同的实例，未注册时返回mynet.ErrUnknownCommand
+func (r *Registry) New(cmd uint64) (proto.Message, error) {
+	r.lock.RLock()
+	t, ok := r.types[cmd]
+	r.lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: 0x%x", mynet.ErrUnknownCommand, cmd)
+	}
+	return reflect.New(t).Interface().(proto.Message), nil
+}
+
+// Commands 已注册的命令字，从小到大排序
+func (r *Registry) Commands() []uint64 {
+	r.lock.RLock()
+	cmds := make([]uint64, 0, len(r.types))
+	for cmd := range r.types {
+		cmds = append(cmds, cmd)
+	}
+	r.lock.RUnlock()
+
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	return cmds
+}
+
+// Register 注册到DefaultRegistry
+func Register(cmd uint64, prototype proto.Message) error {
+	return DefaultRegistry.Register(cmd, prototype)
+}
+
+// MustRegister 注册到DefaultRegistry，失败时panic，用于init
+func MustRegister(cmd uint64, prototype proto.Message) {
+	if err := Register(cmd, prototype); err != nil {
+		panic(err)
+	}
+}
+
+func init() {
+	MustRegister(CMDHeartBeatReq, &HeartBeatReq{}) //0x00010001 // 心跳请求
+	MustRegister(CMDHeartBeatRsp, &HeartBeatRsp{}) //0x00010002
+
+	MustRegister(CMDBizReq, &BizReq{}) //0x00010003
+	MustRegister(CMDBizRsp, &BizRsp{}) //0x00010004
+}
@@ -0,0 +1,81 @@
+package pb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// strMessage 不是结构体指针的proto.Message
+type strMessage string
+
+func (m strMessage) Reset()         {}
+func (m strMessage) String() string { return string(m) }
+func (m strMessage) ProtoMessage()  {}
+
+func TestRegistryNew(t *testing.T) {
+	a, err := DefaultRegistry.New(CMDBizReq)
+	if err != nil {
+		t.Fatalf("new failed, err = %s", err)
+	}
+	b, _ := DefaultRegistry.New(CMDBizReq)
+	if _, ok := a.(*BizReq); !ok {
+		t.Fatalf("type not match, got = %T", a)
+	}
+	if a == b {
+		t.Fatalf("new returned shared instance")
+	}
+	a.(*BizReq).Biz = new(string)
+	if b.(*BizReq).Biz != nil {
+		t.Fatalf("instances share state")
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(0x00020003, &BizReq{}); err != nil {
+		t.Fatalf("register failed, err = %s", err)
+	}
+	if err := r.Register(0x00020001, &BizRsp{}); err != nil {
+		t.Fatalf("register failed, err = %s", err)
+	}
+	if err := r.Register(0x00020003, &BizRsp{}); err == nil {
+		t.Fatalf("duplicate command accepted")
+	}
+	if err := r.Register(0x00020005, nil); err == nil {
+		t.Fatalf("nil prototype accepted")
+	}
+	if err := r.Register(0x00020005, strMessage("")); err == nil {
+		t.Fatalf("non struct pointer prototype accepted")
+	}
+
+	if cmds := r.Commands(); !reflect.DeepEqual(cmds, []uint64{0x00020001, 0x00020003}) {
+		t.Fatalf("commands not sorted, got = %x", cmds)
+	}
+	if m, err := r.New(0x00020003); err != nil || reflect.TypeOf(m) != reflect.TypeOf(&BizReq{}) {
+		t.Fatalf("new failed, m = %T, err = %v", m, err)
+	}
+	// 独立的Registry不影响DefaultRegistry
+	if _, err := DefaultRegistry.New(0x00020003); !errors.Is(err, mynet.ErrUnknownCommand) {
+		t.Fatalf("default registry polluted, err = %v", err)
+	}
+	if _, err := r.New(CMDBizReq); !errors.Is(err, mynet.ErrUnknownCommand) {
+		t.Fatalf("unknown command err = %v", err)
+	}
+}
+
+func TestRegistryParse(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(0x00020003, &BizReq{}); err != nil {
+		t.Fatalf("register failed, err = %s", err)
+	}
+	p := &PbServerProto{Registry: r}
+	if m, err := p.GetMessage(0x00020003); err != nil || m == nil {
+		t.Fatalf("get message failed, err = %v", err)
+	}
+	if _, err := p.GetMessage(CMDBizReq); !errors.Is(err, mynet.ErrUnknownCommand) {
+		t.Fatalf("unknown command err = %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package pb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+	"github.com/golang/protobuf/proto"
+)
+
+// HandlerFunc 处理一个请求，返回的应答为nil时不回包
+type HandlerFunc func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error)
+
+// Middleware 包装HandlerFunc，按Use的顺序由外到内执行
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Router 按命令字分发请求，可以直接作为mynet.Handler使用
+type Router struct {
+	mynet.NopHandler
+
+	lock        sync.RWMutex
+	handlers    map[uint64]HandlerFunc
+	middlewares []Middleware
+	def         HandlerFunc
+
+	// OnDispatchError 处理失败时调用，默认打印
+	OnDispatchError func(conn *mynet.Connection, req *PbProto, err error)
+}
+
+var (
+	typeContext    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeConnection = reflect.TypeOf((*mynet.Connection)(nil))
+	typeMessage    = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	typeError      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NewRouter 创建
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[uint64]HandlerFunc),
+	}
+}
+
+// RspCommand 请求命令字对应的应答命令字
+func RspCommand(cmd uint64) uint64 {
+	return cmd + 1
+}
+
+// Handle 注册命令字的处理函数，handler形如
+// func(ctx context.Context, conn *mynet.Connection, req *BizReq) (*BizRsp, error)，
+// 应答的命令字为RspCommand(cmd)，Extral与请求相同
+func (r *Router) Handle(cmd uint64, handler interface{}) error {
+	h, err := typedHandler(cmd, handler)
+	if err != nil {
+		return err
+	}
+	return r.HandleFunc(cmd, h)
+}
+
+// HandleFunc 注册命令字的处理函数，应答需自行填写命令字
+func (r *Router) HandleFunc(cmd uint64, h HandlerFunc) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.handlers[cmd]; ok {
+		return fmt.Errorf("command 0x%x already registered", cmd)
+	}
+	r.handlers[cmd] = h
+	return nil
+}
+
+// Default 设置未注册命令字的处理函数
+func (r *Router) Default(h HandlerFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.def = h
+}
+
+// Use 添加中间件，对之后分发的请求生效
+func (r *Router) Use(mw ...Middleware) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+func typedHandler(cmd uint64, handler interface{}) (HandlerFunc, error) {
+	fn := reflect.ValueOf(handler)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 3 || t.NumOut() != 2 ||
+		t.In(0) != typeContext || t.In(1) != typeConnection ||
+		!t.In(2).Implements(typeMessage) || !t.Out(0).Implements(typeMessage) ||
+		t.Out(1) != typeError {
+		return nil, fmt.Errorf("command 0x%x: handler should be "+
+			"func(context.Context, *mynet.Connection, Req) (Rsp, error), got %s", cmd, t)
+	}
+	reqType := t.In(2)
+
+	return func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+		if req.B == nil || reflect.TypeOf(req.B) != reqType {
+			return nil, fmt.Errorf("command 0x%x: expect %s, got %T", cmd, reqType, req.B)
+		}
+		out := fn.Call([]reflect.Value{
+			reflect.ValueOf(ctx), reflect.ValueOf(conn), reflect.ValueOf(req.B),
+		})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		if out[0].IsNil() {
+			return nil, nil
+		}
+		rsp := &PbProto{B: out[0].Interface().(proto.Message)}
+		rsp.H.Command = RspCommand(req.H.Command)
+		rsp.H.Extral = req.H.Extral
+		return rsp, nil
+	}, nil
+}
+
+func (r *Router) route(cmd uint64) HandlerFunc {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	h, ok := r.handlers[cmd]
+	if !ok {
+		h = r.def
+	}
+	if h == nil {
+		h = func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+			return nil, fmt.Errorf("%w: 0x%x", mynet.ErrUnknownCommand, req.H.Command)
+		}
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	return h
+}
+
+// Dispatch 分发请求，有应答时发送给conn
+func (r *Router) Dispatch(ctx context.Context, conn *mynet.Connection, req *PbProto) error {
+	rsp, err := r.route(req.H.Command)(ctx, conn, req)
+	if err != nil {
+		return err
+	}
+	if rsp == nil {
+		return nil
+	}
+	return conn.Net().SendDataContext(ctx, conn, rsp)
+}
+
+// OnData mynet.Handler，在回调协程中分发
+func (r *Router) OnData(conn *mynet.Connection, data interface{}) {
+	req, ok := data.(*PbProto)
+	if !ok {
+		return
+	}
+	if err := r.Dispatch(context.Background(), conn, req); err != nil {
+		if r.OnDispatchError != nil {
+			r.OnDispatchError(conn, req, err)
+			return
+		}
+		fmt.Printf("dispatch command 0x%x failed, remote = %s, err = %s\n",
+			req.H.Command, conn.RemoteAddress(), err)
+	}
+}
+
+// Recovery 处理函数panic时转换成错误
+func Recovery() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, conn *mynet.Connection, req *PbProto) (rsp *PbProto, err error) {
+			defer func() {
+				if e := recover(); e != nil {
+					err = fmt.Errorf("command 0x%x panic: %v\n%s", req.H.Command, e, debug.Stack())
+				}
+			}()
+			return next(ctx, conn, req)
+		}
+	}
+}
+
+// Logging 打印请求、应答及耗时
+func Logging(printf func(format string, a ...interface{})) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+			start := time.Now()
+			rsp, err := next(ctx, conn, req)
+			rspCmd := uint64(0)
+			if rsp != nil {
+				rspCmd = rsp.H.Command
+			}
+			printf("command 0x%x -> 0x%x, remote = %s, cost = %s, err = %v\n",
+				req.H.Command, rspCmd, conn.RemoteAddress(), time.Since(start), err)
+			return rsp, err
+		}
+	}
+}
+
+// Metrics 每个请求处理完成后回调observe，用于统计
+func Metrics(observe func(cmd uint64, cost time.Duration, err error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+			start := time.Now()
+			rsp, err := next(ctx, conn, req)
+			observe(req.H.Command, time.Since(start), err)
+			return rsp, err
+		}
+	}
+}
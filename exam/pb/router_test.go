@@ -0,0 +1,142 @@
+package pb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	mynet "github.com/buf1024/golib/net"
+	"github.com/golang/protobuf/proto"
+)
+
+func bizReq(sid string) *PbProto {
+	req := &PbProto{B: &BizReq{SID: proto.String(sid), Biz: proto.String("biz")}}
+	req.H.Command = CMDBizReq
+	req.H.Extral = 0x1234
+	return req
+}
+
+func TestRouterHandleSignature(t *testing.T) {
+	r := NewRouter()
+	bad := []interface{}{
+		"not a func",
+		func(ctx context.Context, req *BizReq) (*BizRsp, error) { return nil, nil },
+		func(ctx context.Context, conn *mynet.Connection, req *BizReq) *BizRsp { return nil },
+		func(ctx context.Context, conn *mynet.Connection, req string) (*BizRsp, error) { return nil, nil },
+		func(ctx context.Context, conn *mynet.Connection, req *BizReq) (*BizRsp, string) { return nil, "" },
+	}
+	for _, h := range bad {
+		err := r.Handle(CMDBizReq, h)
+		if err == nil || !strings.Contains(err.Error(), "handler should be") {
+			t.Fatalf("bad handler %T accepted, err = %v", h, err)
+		}
+	}
+	if len(r.handlers) != 0 {
+		t.Fatalf("bad handler registered")
+	}
+}
+
+func TestRouterTypedHandler(t *testing.T) {
+	r := NewRouter()
+	err := r.Handle(CMDBizReq, func(ctx context.Context, conn *mynet.Connection, req *BizReq) (*BizRsp, error) {
+		return &BizRsp{RetCode: proto.Int32(0), SID: req.SID}, nil
+	})
+	if err != nil {
+		t.Fatalf("handle failed, err = %s", err)
+	}
+
+	rsp, err := r.route(CMDBizReq)(context.Background(), nil, bizReq("s1"))
+	if err != nil {
+		t.Fatalf("route failed, err = %s", err)
+	}
+	if rsp.H.Command != CMDBizRsp || rsp.H.Extral != 0x1234 {
+		t.Fatalf("rsp head not match, got = %+v", rsp.H)
+	}
+	if b, ok := rsp.B.(*BizRsp); !ok || b.GetSID() != "s1" {
+		t.Fatalf("rsp body not match, got = %v", rsp.B)
+	}
+
+	// 包体类型与注册的不一致
+	req := bizReq("s2")
+	req.B = &HeartBeatReq{}
+	if _, err = r.route(CMDBizReq)(context.Background(), nil, req); err == nil {
+		t.Fatalf("mismatched body accepted")
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	r := NewRouter()
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+				order = append(order, name+" in")
+				rsp, err := next(ctx, conn, req)
+				order = append(order, name+" out")
+				return rsp, err
+			}
+		}
+	}
+	r.Use(trace("outer"), Recovery())
+	r.Use(trace("inner"))
+	r.HandleFunc(CMDBizReq, func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+		order = append(order, "handler")
+		panic("boom")
+	})
+
+	_, err := r.route(CMDBizReq)(context.Background(), nil, bizReq("s1"))
+	if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Fatalf("panic not recovered, err = %v", err)
+	}
+	// Recovery在inner外层，inner的out不会执行
+	want := "outer in,inner in,handler,outer out"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("middleware order not match, got = %s, want = %s", got, want)
+	}
+}
+
+func TestRouterDefault(t *testing.T) {
+	r := NewRouter()
+	_, err := r.route(CMDBizReq)(context.Background(), nil, bizReq("s1"))
+	if !errors.Is(err, mynet.ErrUnknownCommand) {
+		t.Fatalf("unknown command err = %v", err)
+	}
+
+	var got error
+	r.OnDispatchError = func(conn *mynet.Connection, req *PbProto, err error) {
+		got = err
+	}
+	r.OnData(nil, bizReq("s1"))
+	if !errors.Is(got, mynet.ErrUnknownCommand) {
+		t.Fatalf("dispatch error not reported, err = %v", got)
+	}
+
+	called := uint64(0)
+	r.Default(func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+		called = req.H.Command
+		return nil, nil
+	})
+	if _, err = r.route(CMDBizReq)(context.Background(), nil, bizReq("s1")); err != nil || called != CMDBizReq {
+		t.Fatalf("default handler not called, err = %v, called = 0x%x", err, called)
+	}
+}
+
+func TestRouterDuplicate(t *testing.T) {
+	r := NewRouter()
+	h := func(ctx context.Context, conn *mynet.Connection, req *PbProto) (*PbProto, error) {
+		return nil, nil
+	}
+	if err := r.HandleFunc(CMDBizReq, h); err != nil {
+		t.Fatalf("handle failed, err = %s", err)
+	}
+	if err := r.HandleFunc(CMDBizReq, h); err == nil {
+		t.Fatalf("duplicate command accepted")
+	}
+	err := r.Handle(CMDBizReq, func(ctx context.Context, conn *mynet.Connection, req *BizReq) (*BizRsp, error) {
+		return nil, nil
+	})
+	if err == nil {
+		t.Fatalf("duplicate typed handler accepted")
+	}
+}
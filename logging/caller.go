@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 记录调用位置的内容，用Log.SetCaller设置，可以组合
+const (
+	// CallerFile 文件、行号和函数，只在调用协程中记录pc，格式化时才解析
+	CallerFile = 1 << iota
+	// CallerGoID 协程id，需要runtime.Stack，开销比CallerFile大
+	CallerGoID
+)
+
+// SetCaller 设置记录调用位置的内容，如CallerFile|CallerGoID，0为不记录
+func (l *Log) SetCaller(flags int) {
+	atomic.StoreInt32(&l.caller, (int32)(flags))
+}
+
+// Output 用于封装Log的函数，skip为跳过的调用层数，0为调用Output的位置，
+// 如封装函数中调用Output(1, ...)记录的是封装函数的调用者
+func (l *Log) Output(skip int, level int64, msg string) {
+	l.output(skip+2, level, msg, nil)
+}
+
+// capture depth为相对capture调用者的层数
+func (l *Log) capture(msg *Message, depth int) {
+	flags := atomic.LoadInt32(&l.caller)
+	if flags&CallerFile != 0 {
+		var pc [1]uintptr
+		// runtime.Callers的0为Callers本身，1为capture
+		if runtime.Callers(depth+2, pc[:]) > 0 {
+			msg.PC = pc[0]
+		}
+	}
+	if flags&CallerGoID != 0 {
+		msg.GoID = GoID()
+	}
+}
+
+// GoID 当前协程的id，从runtime.Stack的第一行"goroutine 123 [running]:"中解析，开销较大
+func GoID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// Frame 调用位置，未记录时为空。结果会缓存，只能在写日志的协程中调用
+func (m *Message) Frame() runtime.Frame {
+	if m.PC != 0 && !m.resolved {
+		m.frame, _ = runtime.CallersFrames([]uintptr{m.PC}).Next()
+		m.resolved = true
+	}
+	return m.frame
+}
+
+// shortFile 文件名，不包含目录，未记录时为???
+func shortFile(file string) string {
+	if file == "" {
+		return "???"
+	}
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		return file[i+1:]
+	}
+	return file
+}
+
+// shortFunc 函数名，去掉包路径，如net.(*SimpleNet).checkConnErr，未记录时为???
+func shortFunc(function string) string {
+	if function == "" {
+		return "???"
+	}
+	if i := strings.LastIndexByte(function, '/'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
+
+// appendCaller 文件名:行号，未记录时为???:0
+func appendCaller(buf []byte, msg *Message) []byte {
+	frame := msg.Frame()
+	buf = append(buf, shortFile(frame.File)...)
+	buf = append(buf, ':')
+	return strconv.AppendInt(buf, (int64)(frame.Line), 10)
+}
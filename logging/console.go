@@ -9,7 +9,11 @@ import (
 var colorLevel = make(map[int64]color.Attribute)
 
 type consoleLogger struct {
-	Level int64 `json:"level"`
+	Level    int64  `json:"level"`
+	Encoding string `json:"encoding"`
+	Format   string `json:"format"`
+
+	formatter Formatter
 }
 
 func (c *consoleLogger) Name() string {
@@ -17,15 +21,22 @@ func (c *consoleLogger) Name() string {
 }
 func (c *consoleLogger) Open(conf string) error {
 	err := json.Unmarshal([]byte(conf), &c)
+	if err != nil {
+		return err
+	}
+	c.formatter, err = newFormatter(c.Encoding, c.Format)
 	return err
 }
 func (c *consoleLogger) Write(msg *Message) (int, error) {
 	n, err := 0, error(nil)
-	if msg.msgType >= c.Level {
-		n, err = color.New(colorLevel[msg.msgType]).Print(msg.message)
+	if msg.Level >= c.Level {
+		n, err = color.New(colorLevel[msg.Level]).Print(string(c.formatter.Format(nil, msg)))
 	}
 	return n, err
 }
+func (c *consoleLogger) SetFormatter(f Formatter) {
+	c.formatter = f
+}
 func (c *consoleLogger) Close() error {
 	return nil
 }
@@ -44,6 +55,7 @@ func init() {
 	colorLevel[LevelTrace] = color.FgHiGreen
 	colorLevel[LevelAll] = color.FgWhite
 
-	c := &consoleLogger{}
-	Register(c)
+	RegisterFactory("console", func() Loger {
+		return &consoleLogger{}
+	})
 }
@@ -0,0 +1,205 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const jsonTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// Field 结构化日志的一个字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Entry 带字段的Log，由Log.With创建，可以在多个协程中使用
+type Entry struct {
+	log    *Log
+	fields []Field
+}
+
+// With 创建带字段的Entry，kv为键值交替的列表，如With("conn_id", id, "remote", addr)
+func (l *Log) With(kv ...interface{}) *Entry {
+	return &Entry{
+		log:    l,
+		fields: appendFields(nil, kv),
+	}
+}
+
+// With 在e的字段之后追加字段，不修改e
+func (e *Entry) With(kv ...interface{}) *Entry {
+	return &Entry{
+		log:    e.log,
+		fields: e.appendFields(kv),
+	}
+}
+
+func (e *Entry) Critical(msg string, kv ...interface{}) {
+	e.output(LevelCritical, msg, kv)
+}
+func (e *Entry) Error(msg string, kv ...interface{}) {
+	e.output(LevelError, msg, kv)
+}
+func (e *Entry) Warning(msg string, kv ...interface{}) {
+	e.output(LevelWarning, msg, kv)
+}
+func (e *Entry) Notice(msg string, kv ...interface{}) {
+	e.output(LevelNotice, msg, kv)
+}
+func (e *Entry) Info(msg string, kv ...interface{}) {
+	e.output(LevelInformational, msg, kv)
+}
+func (e *Entry) Debug(msg string, kv ...interface{}) {
+	e.output(LevelDebug, msg, kv)
+}
+func (e *Entry) Trace(msg string, kv ...interface{}) {
+	e.output(LevelTrace, msg, kv)
+}
+
+func (e *Entry) output(level int64, msg string, kv []interface{}) {
+	e.log.output(3, level, msg, e.appendFields(kv))
+}
+
+// appendFields 复制e的字段后追加，e的字段可能被多个协程共享
+func (e *Entry) appendFields(kv []interface{}) []Field {
+	if len(kv) == 0 {
+		return e.fields
+	}
+	fields := make([]Field, len(e.fields), len(e.fields)+(len(kv)+1)/2)
+	copy(fields, e.fields)
+	return appendFields(fields, kv)
+}
+
+// appendFields 键不是string时用fmt.Sprint转换，缺少值的键记为!BADKEY
+func appendFields(fields []Field, kv []interface{}) []Field {
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			fields = append(fields, Field{Key: "!BADKEY", Value: kv[i]})
+			break
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		fields = append(fields, Field{Key: key, Value: kv[i+1]})
+	}
+	return fields
+}
+
+// appendText [时间][级别][Log名字][g协程id] 文件:行号 消息 key=value...
+func appendText(buf []byte, msg *Message) []byte {
+	now := msg.Time
+	buf = fmt.Appendf(buf, "[%02d%02d%02d.%06d]%s",
+		now.Hour(), now.Minute(), now.Second(), now.Nanosecond()/1000,
+		levelHeadString[msg.Level])
+	if msg.Logger != "" {
+		buf = append(buf, '[')
+		buf = append(buf, msg.Logger...)
+		buf = append(buf, ']')
+	}
+	if msg.GoID != 0 {
+		buf = append(buf, "[g"...)
+		buf = strconv.AppendUint(buf, msg.GoID, 10)
+		buf = append(buf, ']')
+	}
+	buf = append(buf, ' ')
+	if msg.PC != 0 {
+		buf = appendCaller(buf, msg)
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, strings.TrimSuffix(msg.Msg, "\n")...)
+	buf = appendTextFields(buf, msg.Fields)
+	return append(buf, '\n')
+}
+
+func appendTextFields(buf []byte, fields []Field) []byte {
+	for _, f := range fields {
+		buf = append(buf, ' ')
+		buf = append(buf, f.Key...)
+		buf = append(buf, '=')
+		buf = appendTextValue(buf, f.Value)
+	}
+	return buf
+}
+
+func appendTextValue(buf []byte, v interface{}) []byte {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+// appendJSON 一行一个json对象，字段与time、level等固定的键重名时加上"fields."前缀
+func appendJSON(buf []byte, msg *Message) []byte {
+	buf = append(buf, `{"time":`...)
+	buf = appendJSONValue(buf, msg.Time.Format(jsonTimeLayout))
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONValue(buf, levelNameString[msg.Level])
+	if msg.Logger != "" {
+		buf = append(buf, `,"logger":`...)
+		buf = appendJSONValue(buf, msg.Logger)
+	}
+	if msg.PC != 0 {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONValue(buf, string(appendCaller(nil, msg)))
+		buf = append(buf, `,"func":`...)
+		buf = appendJSONValue(buf, msg.Frame().Function)
+	}
+	if msg.GoID != 0 {
+		buf = append(buf, `,"goid":`...)
+		buf = strconv.AppendUint(buf, msg.GoID, 10)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONValue(buf, strings.TrimSuffix(msg.Msg, "\n"))
+	for _, f := range msg.Fields {
+		key := f.Key
+		switch key {
+		case "time", "level", "logger", "caller", "func", "goid", "msg":
+			key = "fields." + key
+		}
+		buf = append(buf, ',')
+		buf = appendJSONValue(buf, key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, f.Value)
+	}
+	return append(buf, "}\n"...)
+}
+
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case error:
+		v = safeString(v, val.Error)
+	case time.Duration:
+		v = val.String()
+	case fmt.Stringer:
+		if _, ok := v.(json.Marshaler); !ok {
+			v = safeString(v, val.String)
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+// safeString 调用Error、String，与fmt一样处理方法中的panic，nil指针为<nil>，
+// 异步模式下在写日志的协程中格式化，panic会导致进程退出
+func safeString(v interface{}, fn func() string) (s string) {
+	defer func() {
+		if e := recover(); e != nil {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+				s = "<nil>"
+				return
+			}
+			s = fmt.Sprintf("%%!v(PANIC=%v)", e)
+		}
+	}()
+	return fn()
+}
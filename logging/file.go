@@ -15,8 +15,11 @@ type fileLogger struct {
 	Level      int64  `json:"level"`
 	SwitchSize int64  `json:"switchsize"`
 	SwitchTime int64  `json:"switchtime"`
+	Encoding   string `json:"encoding"`
+	Format     string `json:"format"`
 
-	status bool
+	status    bool
+	formatter Formatter
 
 	file      *os.File
 	fileDate  int64
@@ -75,7 +78,7 @@ func (f *fileLogger) Name() string {
 	return "file"
 }
 
-//`{"prefix":"hello", "filedir":"./", "level":0, "switchsize":1024, "switchtime":86400}`)
+//`{"prefix":"hello", "filedir":"./", "level":0, "switchsize":1024, "switchtime":86400, "format":"%date %time.%micro [%level] %msg%fields"}`)
 func (f *fileLogger) Open(conf string) error {
 	err := json.Unmarshal([]byte(conf), f)
 	if err != nil {
@@ -91,6 +94,9 @@ func (f *fileLogger) Open(conf string) error {
 	if f.Level < 0 || f.Level > LevelCritical {
 		return fmt.Errorf("level must between(%d ~ %d)", LevelAll, LevelCritical)
 	}
+	if f.formatter, err = newFormatter(f.Encoding, f.Format); err != nil {
+		return err
+	}
 
 	f.FileDir = filepath.Dir(f.FileDir)
 	if !strings.HasSuffix(f.FileDir, string(filepath.Separator)) {
@@ -101,8 +107,8 @@ func (f *fileLogger) Open(conf string) error {
 func (f *fileLogger) Write(msg *Message) (int, error) {
 	n, err := 0, error(nil)
 	if f.file != nil {
-		if msg.msgType >= f.Level {
-			n, err = f.file.Write([]byte(msg.message))
+		if msg.Level >= f.Level {
+			n, err = f.file.Write(f.formatter.Format(nil, msg))
 			if err != nil {
 				return n, err
 			}
@@ -116,6 +122,9 @@ func (f *fileLogger) Write(msg *Message) (int, error) {
 	}
 	return n, err
 }
+func (f *fileLogger) SetFormatter(formatter Formatter) {
+	f.formatter = formatter
+}
 func (f *fileLogger) Close() error {
 	if f.file != nil {
 		err := f.file.Close()
@@ -140,6 +149,7 @@ func (f *fileLogger) Sync() error {
 
 func init() {
 
-	f := &fileLogger{}
-	Register(f)
+	RegisterFactory("file", func() Loger {
+		return &fileLogger{}
+	})
 }
@@ -0,0 +1,225 @@
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 输出的编码方式，在输出的json配置中用"encoding"指定
+const (
+	EncodingText = "text"
+	EncodingJSON = "json"
+)
+
+// Formatter 把Message格式化成一行追加到buf，以\n结尾。
+// 同一个输出的Write是串行的，Formatter不需要并发安全
+type Formatter interface {
+	Format(buf []byte, msg *Message) []byte
+}
+
+// TextFormatter 默认格式：[时分秒.微秒][级别][Log名字][g协程id] 文件:行号 消息 key=value...
+type TextFormatter struct{}
+
+func (TextFormatter) Format(buf []byte, msg *Message) []byte {
+	return appendText(buf, msg)
+}
+
+// JSONFormatter 一行一个json对象
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(buf []byte, msg *Message) []byte {
+	return appendJSON(buf, msg)
+}
+
+// newFormatter 根据输出的json配置创建，format不为空时使用PatternFormatter
+func newFormatter(encoding string, format string) (Formatter, error) {
+	switch encoding {
+	case "", EncodingText:
+		if format != "" {
+			return NewPatternFormatter(format)
+		}
+		return TextFormatter{}, nil
+	case EncodingJSON:
+		if format != "" {
+			return nil, fmt.Errorf("format not support with encoding %s", encoding)
+		}
+		return JSONFormatter{}, nil
+	}
+	return nil, fmt.Errorf("encoding %s not support", encoding)
+}
+
+type patternPart func(buf []byte, msg *Message) []byte
+
+// PatternFormatter 按模式格式化，如"%date %time.%micro [%level] %file:%line %msg"，
+// 支持的占位符：
+//
+//	%date   2006-01-02
+//	%time   15:04:05
+//	%hms    150405
+//	%milli  毫秒，3位
+//	%micro  微秒，6位
+//	%tz     时区，如+08:00
+//	%level  级别，如INFO
+//	%lv     级别缩写，如I
+//	%logger Log的名字
+//	%msg    消息，去掉结尾的\n
+//	%fields 结构化字段，每个为" key=value"
+//	%caller 文件名:行号，需要SetCaller(CallerFile)，未记录时为???:0，下同
+//	%file   文件名
+//	%path   文件完整路径，未记录时为空
+//	%line   行号
+//	%func   函数名，不包含包路径
+//	%gid    协程id，需要SetCaller(CallerGoID)
+//	%%      %
+type PatternFormatter struct {
+	pattern string
+	parts   []patternPart
+}
+
+var patternVerbs = map[string]patternPart{
+	"date": func(buf []byte, msg *Message) []byte {
+		return msg.Time.AppendFormat(buf, "2006-01-02")
+	},
+	"time": func(buf []byte, msg *Message) []byte {
+		return msg.Time.AppendFormat(buf, "15:04:05")
+	},
+	"hms": func(buf []byte, msg *Message) []byte {
+		return msg.Time.AppendFormat(buf, "150405")
+	},
+	"milli": func(buf []byte, msg *Message) []byte {
+		return appendPadded(buf, msg.Time.Nanosecond()/1000000, 3)
+	},
+	"micro": func(buf []byte, msg *Message) []byte {
+		return appendPadded(buf, msg.Time.Nanosecond()/1000, 6)
+	},
+	"tz": func(buf []byte, msg *Message) []byte {
+		return msg.Time.AppendFormat(buf, "-07:00")
+	},
+	"level": func(buf []byte, msg *Message) []byte {
+		return append(buf, strings.ToUpper(levelNameString[msg.Level])...)
+	},
+	"lv": func(buf []byte, msg *Message) []byte {
+		return append(buf, strings.Trim(levelHeadString[msg.Level], "[]")...)
+	},
+	"logger": func(buf []byte, msg *Message) []byte {
+		return append(buf, msg.Logger...)
+	},
+	"msg": func(buf []byte, msg *Message) []byte {
+		return append(buf, strings.TrimSuffix(msg.Msg, "\n")...)
+	},
+	"fields": func(buf []byte, msg *Message) []byte {
+		return appendTextFields(buf, msg.Fields)
+	},
+	"caller": func(buf []byte, msg *Message) []byte {
+		return appendCaller(buf, msg)
+	},
+	"file": func(buf []byte, msg *Message) []byte {
+		return append(buf, shortFile(msg.Frame().File)...)
+	},
+	"path": func(buf []byte, msg *Message) []byte {
+		return append(buf, msg.Frame().File...)
+	},
+	"line": func(buf []byte, msg *Message) []byte {
+		return strconv.AppendInt(buf, (int64)(msg.Frame().Line), 10)
+	},
+	"func": func(buf []byte, msg *Message) []byte {
+		return append(buf, shortFunc(msg.Frame().Function)...)
+	},
+	"gid": func(buf []byte, msg *Message) []byte {
+		return strconv.AppendUint(buf, msg.GoID, 10)
+	},
+}
+
+// NewPatternFormatter 解析pattern，占位符为%后的字母，不支持的占位符返回错误
+func NewPatternFormatter(pattern string) (*PatternFormatter, error) {
+	p := &PatternFormatter{pattern: pattern}
+	literal := []byte(nil)
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			literal = append(literal, pattern[i])
+			continue
+		}
+		if i+1 < len(pattern) && pattern[i+1] == '%' {
+			literal = append(literal, '%')
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(pattern) && pattern[j] >= 'a' && pattern[j] <= 'z' {
+			j++
+		}
+		verb := pattern[i+1 : j]
+		part, ok := patternVerbs[verb]
+		if !ok {
+			return nil, fmt.Errorf("format %q: unknown verb %%%s at %d", pattern, verb, i)
+		}
+		if len(literal) > 0 {
+			p.parts = append(p.parts, literalPart(string(literal)))
+			literal = nil
+		}
+		p.parts = append(p.parts, part)
+		i = j - 1
+	}
+	if len(literal) > 0 {
+		p.parts = append(p.parts, literalPart(string(literal)))
+	}
+	return p, nil
+}
+
+// String 创建时的pattern
+func (p *PatternFormatter) String() string {
+	return p.pattern
+}
+
+// Format Formatter
+func (p *PatternFormatter) Format(buf []byte, msg *Message) []byte {
+	for _, part := range p.parts {
+		buf = part(buf, msg)
+	}
+	return append(buf, '\n')
+}
+
+func literalPart(s string) patternPart {
+	return func(buf []byte, msg *Message) []byte {
+		return append(buf, s...)
+	}
+}
+
+func appendPadded(buf []byte, n int, width int) []byte {
+	var tmp [20]byte
+	b := strconv.AppendInt(tmp[:0], int64(n), 10)
+	for i := len(b); i < width; i++ {
+		buf = append(buf, '0')
+	}
+	return append(buf, b...)
+}
+
+// formatterSetter 支持SetFormatter的输出
+type formatterSetter interface {
+	SetFormatter(f Formatter)
+}
+
+// SetFormatter 替换名为instance的输出的Formatter，console、file输出支持
+func (l *Log) SetFormatter(instance string, f Formatter) error {
+	var (
+		found bool
+		err   error
+	)
+	l.sinks.each(func(name string, log Loger) {
+		if name != instance {
+			return
+		}
+		found = true
+		setter, ok := log.(formatterSetter)
+		if !ok {
+			err = fmt.Errorf("sink %s not support formatter", instance)
+			return
+		}
+		setter.SetFormatter(f)
+	})
+	if !found {
+		return fmt.Errorf("sink %s not found", instance)
+	}
+	return err
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -37,14 +38,30 @@ type Loger interface {
 	Sync() error
 }
 
+// Message 一条日志，由输出按配置编码
 type Message struct {
-	msgType int64
-	message string
+	Time  time.Time
+	Level int64
+	// Logger Log的名字
+	Logger string
+	Msg    string
+	// Fields 结构化日志的字段，按添加顺序
+	Fields []Field
+	// PC 调用位置，SetCaller包含CallerFile时记录，用Frame解析
+	PC uintptr
+	// GoID 调用协程的id，SetCaller包含CallerGoID时记录
+	GoID uint64
+
+	frame    runtime.Frame
+	resolved bool
 }
 
 type Log struct {
+	name    string
+	sinks   *sinkSet
 	status  int64
 	sync    bool
+	caller  int32
 	mutex   sync.Mutex
 	logMsg  chan *Message
 	sigMsg  chan string
@@ -53,139 +70,72 @@ type Log struct {
 
 var levelString = make(map[string]int64)
 var levelHeadString = make(map[int64]string)
-var loggerRegistered = make(map[string]Loger)
-var loggerTraced = make(map[string]Loger)
+var levelNameString = make(map[int64]string)
 
-func (l *Log) Critical(format string, a ...interface{}) {
-	if l.status != statusRunning {
-		fmt.Printf("Critical logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
-		return
-	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelCritical])
+// Factory 创建输出实例，每次AddSink都创建新的实例
+type Factory func() Loger
 
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelCritical
+var registerLock sync.RWMutex
+var loggerRegistered = make(map[string]Factory)
 
-	l.logMessage(chanMsg, logMsg, format, a...)
-}
+// defaultLog SetupLog添加的输出属于默认Log
+var defaultLog = &Log{sinks: defaultSinks}
 
+func (l *Log) Critical(format string, a ...interface{}) {
+	l.output(2, LevelCritical, fmt.Sprintf(format, a...), nil)
+}
 func (l *Log) Error(format string, a ...interface{}) {
-	if l.status != statusRunning {
-		fmt.Printf("Error logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
-		return
-	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelError])
-
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelError
-
-	l.logMessage(chanMsg, logMsg, format, a...)
+	l.output(2, LevelError, fmt.Sprintf(format, a...), nil)
 }
-
 func (l *Log) Warning(format string, a ...interface{}) {
-	if l.status != statusRunning {
-		fmt.Printf("Warning logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
-		return
-	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelWarning])
-
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelWarning
-
-	l.logMessage(chanMsg, logMsg, format, a...)
+	l.output(2, LevelWarning, fmt.Sprintf(format, a...), nil)
 }
 func (l *Log) Notice(format string, a ...interface{}) {
-	if l.status != statusRunning {
-		fmt.Printf("Notice logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
-		return
-	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelNotice])
-
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelNotice
-
-	l.logMessage(chanMsg, logMsg, format, a...)
+	l.output(2, LevelNotice, fmt.Sprintf(format, a...), nil)
 }
-
 func (l *Log) Info(format string, a ...interface{}) {
-	if l.status != statusRunning {
-		fmt.Printf("Info logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
-		return
-	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelInformational])
-
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelInformational
-
-	l.logMessage(chanMsg, logMsg, format, a...)
+	l.output(2, LevelInformational, fmt.Sprintf(format, a...), nil)
 }
-
 func (l *Log) Debug(format string, a ...interface{}) {
-	if l.status != statusRunning {
-		fmt.Printf("Debug logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
-		return
-	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelDebug])
-
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelDebug
-
-	l.logMessage(chanMsg, logMsg, format, a...)
+	l.output(2, LevelDebug, fmt.Sprintf(format, a...), nil)
 }
 func (l *Log) Trace(format string, a ...interface{}) {
+	l.output(2, LevelTrace, fmt.Sprintf(format, a...), nil)
+}
+
+// output 格式化由各个输出的Formatter完成，depth为调用位置相对output的层数
+func (l *Log) output(depth int, level int64, msg string, fields []Field) {
 	if l.status != statusRunning {
-		fmt.Printf("Trace logging status not right, status = %d, msg = %s\n",
-			l.status, fmt.Sprintf(format, a...))
+		fmt.Printf("%s logging status not right, status = %d, msg = %s\n",
+			levelNameString[level], l.status, msg)
 		return
 	}
-	now := time.Now()
-	logMsg := fmt.Sprintf("[%02d%02d%02d.%06d]%s ",
-		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-		levelHeadString[LevelTrace])
-
-	chanMsg := &Message{}
-	chanMsg.msgType = LevelTrace
+	chanMsg := l.newMessage(level, msg, fields)
+	l.capture(chanMsg, depth)
+	l.logMessage(chanMsg)
+}
 
-	l.logMessage(chanMsg, logMsg, format, a...)
+func (l *Log) newMessage(level int64, msg string, fields []Field) *Message {
+	return &Message{
+		Time:   time.Now(),
+		Level:  level,
+		Logger: l.name,
+		Msg:    msg,
+		Fields: fields,
+	}
 }
-func (l *Log) logMessage(chanMsg *Message, logMsg string, format string, a ...interface{}) {
+
+func (l *Log) logMessage(chanMsg *Message) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	chanMsg.message = fmt.Sprintf(format, a...)
-	chanMsg.message = fmt.Sprintf("%s%s", logMsg, chanMsg.message)
-
 	if l.sync {
-		for _, log := range loggerTraced {
+		l.sinks.each(func(name string, log Loger) {
 			_, err := log.Write(chanMsg)
 			if err != nil {
-				fmt.Printf("write log message failed. msg = %s\n", chanMsg.message)
+				fmt.Printf("write log message failed. msg = %s\n", chanMsg.Msg)
 			}
-		}
+		})
 		return
 	}
 
@@ -201,12 +151,12 @@ func (l *Log) Sync() {
 	defer l.mutex.Unlock()
 
 	if l.sync {
-		for _, log := range loggerTraced {
+		l.sinks.each(func(name string, log Loger) {
 			err := log.Sync()
 			if err != nil {
 				fmt.Printf("sync message failed\n")
 			}
-		}
+		})
 		return
 	}
 	l.syncMsg <- struct{}{}
@@ -215,7 +165,7 @@ func (l *Log) Sync() {
 func (l *Log) StartSync() error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	if len(loggerTraced) == 0 {
+	if l.sinks.len() == 0 {
 		return fmt.Errorf("log size zero")
 	}
 	l.status = statusRunning
@@ -226,7 +176,7 @@ func (l *Log) StartSync() error {
 func (l *Log) StartAsync() error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	if len(loggerTraced) == 0 {
+	if l.sinks.len() == 0 {
 		return fmt.Errorf("log size zero")
 	}
 	l.status = statusRunning
@@ -263,20 +213,10 @@ func (l *Log) Stop() {
 			}
 		} else {
 			// exit logger
-			for _, log := range loggerTraced {
-				err := log.Close()
-				if err != nil {
-					fmt.Printf("log close failed.\n")
-				}
-			}
+			l.sinks.closeAll()
 			l.status = statusClosed
 		}
 	}
-	if l.status == statusClosed {
-		for k, _ := range loggerTraced {
-			delete(loggerTraced, k)
-		}
-	}
 }
 
 func (l *Log) waitMsg() {
@@ -284,20 +224,20 @@ END:
 	for {
 		select {
 		case <-l.syncMsg:
-			for _, log := range loggerTraced {
+			l.sinks.each(func(name string, log Loger) {
 				err := log.Sync()
 				if err != nil {
 					fmt.Printf("sync message failed\n")
 				}
-			}
+			})
 		case msg := <-l.logMsg:
-			for _, log := range loggerTraced {
+			l.sinks.each(func(name string, log Loger) {
 				_, err := log.Write(msg)
 				if err != nil {
 					fmt.Printf("log write message failed, logger = %s, type = %d, message = %s, err = %s\n",
-						log.Name(), msg.msgType, msg.message, err.Error())
+						name, msg.Level, msg.Msg, err.Error())
 				}
-			}
+			})
 			if l.status == statusClosing {
 				if len(l.logMsg) == 0 {
 					break END
@@ -315,20 +255,36 @@ END:
 	}
 
 	// exit logger
-	for _, log := range loggerTraced {
-		err := log.Close()
-		if err != nil {
-			fmt.Printf("log close failed.\n")
-		}
-	}
+	l.sinks.closeAll()
 	l.sigMsg <- "closed"
 }
 
+// NewLogging 创建共享默认输出的Log，输出由SetupLog添加，兼容旧的用法
 func NewLogging() (*Log, error) {
-	log := &Log{}
+	log := &Log{
+		sinks: defaultSinks,
+	}
 	return log, nil
 }
 
+// New 创建有独立输出的Log，输出由AddSink添加，如网络层和业务分别输出
+func New(name string) *Log {
+	return &Log{
+		name:  name,
+		sinks: newSinkSet(),
+	}
+}
+
+// Default 默认Log，与NewLogging创建的Log共享输出
+func Default() *Log {
+	return defaultLog
+}
+
+// Name Log的名字，NewLogging创建的为空
+func (l *Log) Name() string {
+	return l.name
+}
+
 func LogLevel(levelStr string) (int64, error) {
 	str := strings.ToLower(levelStr)
 	if level, ok := levelString[str]; ok {
@@ -337,29 +293,48 @@ func LogLevel(levelStr string) (int64, error) {
 	return LevelAll, fmt.Errorf("level %s not found", levelStr)
 }
 
+// SetupLog 给默认Log添加输出
 func SetupLog(name string, conf string) (Loger, error) {
-	if log, ok := loggerRegistered[name]; ok {
-		err := log.Open(conf)
-		if err != nil {
-			return nil, err
-		}
-		loggerTraced[name] = log
+	return defaultLog.AddSink(name, conf)
+}
 
-		return log, nil
-	}
-	return nil, fmt.Errorf("loger %s not found", name)
+// SetupLogAs 给默认Log添加名为instance的输出，同一类型可以添加多个
+func SetupLogAs(instance string, name string, conf string) (Loger, error) {
+	return defaultLog.AddSinkAs(instance, name, conf)
 }
 
+// Register 以log.Name()注册输出类型，兼容旧接口，所有AddSink共用log这一个实例。
+// 需要每次AddSink创建新实例时用RegisterFactory
 func Register(log Loger) error {
-	name := log.Name()
+	return RegisterFactory(log.Name(), func() Loger {
+		return log
+	})
+}
+
+// RegisterFactory 注册名为name的输出类型
+func RegisterFactory(name string, factory Factory) error {
+	registerLock.Lock()
+	defer registerLock.Unlock()
+
 	if _, ok := loggerRegistered[name]; ok {
 		return fmt.Errorf("logger %s exists", name)
 	}
-	loggerRegistered[name] = log
+	loggerRegistered[name] = factory
 
 	return nil
 }
 
+func newLoger(name string) (Loger, error) {
+	registerLock.RLock()
+	defer registerLock.RUnlock()
+
+	factory, ok := loggerRegistered[name]
+	if !ok {
+		return nil, fmt.Errorf("loger %s not found", name)
+	}
+	return factory(), nil
+}
+
 func init() {
 	levelString["all"] = LevelAll
 	levelString["trace"] = LevelTrace
@@ -379,4 +354,8 @@ func init() {
 	levelHeadString[LevelError] = "[E]"
 	levelHeadString[LevelCritical] = "[C]"
 
+	for str, level := range levelString {
+		levelNameString[level] = str
+	}
+
 }
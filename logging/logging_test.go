@@ -1,6 +1,16 @@
 package logging
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -43,3 +53,422 @@ func TestLogging(t *testing.T) {
 	}
 	log.Stop()
 }
+
+var sinkSeq int64
+
+// sinkName 注册表是全局的，-count=N重复运行时每次注册不同的名字
+func sinkName(t testing.TB, prefix string) string {
+	return fmt.Sprintf("%s_%s_%d", prefix, t.Name(), atomic.AddInt64(&sinkSeq, 1))
+}
+
+// memLogger 记录写入的消息，用于检查输出
+type memLogger struct {
+	name   string
+	lock   sync.Mutex
+	msgs   []string
+	closed bool
+}
+
+func (m *memLogger) Name() string {
+	return m.name
+}
+func (m *memLogger) Open(conf string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.closed = false
+	return nil
+}
+func (m *memLogger) Write(msg *Message) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	line := string(appendText(nil, msg))
+	m.msgs = append(m.msgs, line)
+	return len(line), nil
+}
+func (m *memLogger) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.closed = true
+	return nil
+}
+func (m *memLogger) Sync() error {
+	return nil
+}
+func (m *memLogger) messages() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]string(nil), m.msgs...)
+}
+
+func TestLogInstances(t *testing.T) {
+	netSink, bizSink := &memLogger{name: sinkName(t, "mem_net")}, &memLogger{name: sinkName(t, "mem_biz")}
+	// Register共用注册的实例
+	for _, sink := range []*memLogger{netSink, bizSink} {
+		if err := Register(sink); err != nil {
+			t.Fatalf("register failed, err = %s", err)
+		}
+	}
+
+	netLog, bizLog := New("net"), New("biz")
+	if _, err := netLog.AddSink(netSink.name, "{}"); err != nil {
+		t.Fatalf("add sink failed, err = %s", err)
+	}
+	if _, err := bizLog.AddSink(bizSink.name, "{}"); err != nil {
+		t.Fatalf("add sink failed, err = %s", err)
+	}
+	// 重名时不能影响已经添加的共用实例
+	if _, err := netLog.AddSink(netSink.name, "{}"); err == nil {
+		t.Fatalf("duplicate sink accepted")
+	}
+	if netSink.closed {
+		t.Fatalf("duplicate sink closed the attached instance")
+	}
+	if _, err := bizLog.AddSink("not_exists", "{}"); err == nil {
+		t.Fatalf("add unknown sink succeeded")
+	}
+	if err := netLog.StartSync(); err != nil {
+		t.Fatalf("start failed, err = %s", err)
+	}
+	if err := bizLog.Start(); err != nil {
+		t.Fatalf("start failed, err = %s", err)
+	}
+
+	netLog.Info("net message\n")
+	bizLog.Info("biz message\n")
+	netLog.Stop()
+	bizLog.Info("after net stop\n")
+	bizLog.Stop()
+
+	if msgs := netSink.messages(); len(msgs) != 1 || !strings.HasSuffix(msgs[0], "net message\n") {
+		t.Fatalf("net sink messages not match, got = %q", msgs)
+	}
+	if msgs := bizSink.messages(); len(msgs) != 2 || !strings.HasSuffix(msgs[1], "after net stop\n") {
+		t.Fatalf("biz sink messages not match, got = %q", msgs)
+	}
+	if !netSink.closed || !bizSink.closed || len(netLog.Sinks()) != 0 {
+		t.Fatalf("sinks not closed")
+	}
+	if Default().Sinks() != nil {
+		t.Fatalf("default log should have no sinks, got = %v", Default().Sinks())
+	}
+}
+
+func TestMultipleFileSinks(t *testing.T) {
+	dir := t.TempDir() + string(filepath.Separator)
+	log := New("app")
+	_, err := log.AddSinkAs("error", "file",
+		fmt.Sprintf(`{"prefix":"error", "filedir":%q, "level":%d, "switchtime":-1}`, dir, LevelError))
+	if err != nil {
+		t.Fatalf("add error sink failed, err = %s", err)
+	}
+	_, err = log.AddSinkAs("access", "file",
+		fmt.Sprintf(`{"prefix":"access", "filedir":%q, "level":0, "switchtime":-1}`, dir))
+	if err != nil {
+		t.Fatalf("add access sink failed, err = %s", err)
+	}
+	if _, err = log.AddSinkAs("access", "console", `{"level":0}`); err == nil {
+		t.Fatalf("duplicate sink name accepted")
+	}
+	if sinks := log.Sinks(); strings.Join(sinks, ",") != "access,error" {
+		t.Fatalf("sinks not match, got = %v", sinks)
+	}
+
+	log.StartSync()
+	log.Info("request done\n")
+	log.Error("request failed\n")
+	log.Stop()
+
+	read := func(prefix string) string {
+		files, _ := filepath.Glob(filepath.Join(dir, prefix+"_*.log"))
+		if len(files) != 1 {
+			t.Fatalf("%s log files not match, got = %v", prefix, files)
+		}
+		b, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatalf("read %s failed, err = %s", files[0], err)
+		}
+		return string(b)
+	}
+	if got := read("error"); strings.Contains(got, "request done") || !strings.Contains(got, "request failed") {
+		t.Fatalf("error log not match, got = %q", got)
+	}
+	if got := read("access"); !strings.Contains(got, "request done") || !strings.Contains(got, "request failed") {
+		t.Fatalf("access log not match, got = %q", got)
+	}
+}
+
+func TestStructuredJSON(t *testing.T) {
+	dir := t.TempDir() + string(filepath.Separator)
+	log := New("net")
+	_, err := log.AddSink("file",
+		fmt.Sprintf(`{"prefix":"json", "filedir":%q, "level":0, "switchtime":-1, "encoding":"json"}`, dir))
+	if err != nil {
+		t.Fatalf("add file sink failed, err = %s", err)
+	}
+	if _, err = log.AddSinkAs("bad", "console", `{"encoding":"xml"}`); err == nil {
+		t.Fatalf("unknown encoding accepted")
+	}
+
+	log.StartSync()
+	conn := log.With("conn_id", 7, "remote", "127.0.0.1:8080")
+	conn.Info("accepted", "bytes", 128)
+	conn.With("msg", "shadow").Error("closed", "err", errors.New("EOF"), "dangling")
+	log.Info("plain %d\n", 1)
+	log.Stop()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "json_*.log"))
+	if len(files) != 1 {
+		t.Fatalf("log files not match, got = %v", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("open %s failed, err = %s", files[0], err)
+	}
+	defer f.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line not json, line = %s, err = %s", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("lines not match, got = %v", lines)
+	}
+	if l := lines[0]; l["level"] != "info" || l["logger"] != "net" || l["msg"] != "accepted" ||
+		l["conn_id"] != 7.0 || l["remote"] != "127.0.0.1:8080" || l["bytes"] != 128.0 {
+		t.Fatalf("first line not match, got = %v", l)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, lines[0]["time"].(string)); err != nil {
+		t.Fatalf("time not RFC3339, got = %v", lines[0]["time"])
+	}
+	if l := lines[1]; l["level"] != "error" || l["msg"] != "closed" || l["fields.msg"] != "shadow" ||
+		l["err"] != "EOF" || l["!BADKEY"] != "dangling" || l["conn_id"] != 7.0 {
+		t.Fatalf("second line not match, got = %v", l)
+	}
+	if l := lines[2]; l["msg"] != "plain 1" || len(l) != 4 {
+		t.Fatalf("third line not match, got = %v", l)
+	}
+}
+
+func TestStructuredText(t *testing.T) {
+	msg := &Message{
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 6000, time.Local),
+		Level:  LevelWarning,
+		Logger: "net",
+		Msg:    "slow request\n",
+		Fields: []Field{{"cost", time.Second}, {"path", "/a b"}},
+	}
+	want := "[030405.000006][W][net] slow request cost=1s path=\"/a b\"\n"
+	if got := string(appendText(nil, msg)); got != want {
+		t.Fatalf("text not match, got = %q, want = %q", got, want)
+	}
+}
+
+func TestPatternFormatter(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	msg := &Message{
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 123456789, loc),
+		Level:  LevelInformational,
+		Logger: "net",
+		Msg:    "accepted\n",
+		Fields: []Field{{"conn_id", 7}},
+	}
+	cases := []struct {
+		pattern string
+		want    string
+	}{
+		{"%date %time.%micro%tz [%level] %msg%fields", "2020-01-02 03:04:05.123456+08:00 [INFO] accepted conn_id=7\n"},
+		{"[%hms.%milli][%lv][%logger] 100%% %msg", "[030405.123][I][net] 100% accepted\n"},
+		{"%msg", "accepted\n"},
+		// 没有记录调用位置
+		{"%date %time.%micro [%level] %file:%line %msg", "2020-01-02 03:04:05.123456 [INFO] ???:0 accepted\n"},
+	}
+	for _, c := range cases {
+		f, err := NewPatternFormatter(c.pattern)
+		if err != nil {
+			t.Fatalf("pattern %q failed, err = %s", c.pattern, err)
+		}
+		if got := string(f.Format(nil, msg)); got != c.want {
+			t.Fatalf("pattern %q not match, got = %q, want = %q", c.pattern, got, c.want)
+		}
+	}
+	f, _ := NewPatternFormatter("%date %time.%micro [%level] %file:%line %msg")
+	var line int
+	msg.PC, _, line, _ = runtime.Caller(0)
+	want := fmt.Sprintf("2020-01-02 03:04:05.123456 [INFO] logging_test.go:%d accepted\n", line)
+	if got := string(f.Format(nil, msg)); got != want {
+		t.Fatalf("caller pattern not match, got = %q, want = %q", got, want)
+	}
+
+	for _, pattern := range []string{"%nope", "%msg %", "%5msg"} {
+		if _, err := NewPatternFormatter(pattern); err == nil {
+			t.Fatalf("bad pattern %q accepted", pattern)
+		}
+	}
+}
+
+func TestSinkFormat(t *testing.T) {
+	dir := t.TempDir() + string(filepath.Separator)
+	log := New("app")
+	_, err := log.AddSink("file",
+		fmt.Sprintf(`{"prefix":"fmt", "filedir":%q, "level":0, "switchtime":-1, "format":"%%level|%%msg"}`, dir))
+	if err != nil {
+		t.Fatalf("add file sink failed, err = %s", err)
+	}
+	if _, err = log.AddSinkAs("bad", "console", `{"format":"%unknown"}`); err == nil {
+		t.Fatalf("bad format accepted")
+	}
+	if _, err = log.AddSinkAs("bad", "console", `{"encoding":"json", "format":"%msg"}`); err == nil {
+		t.Fatalf("format with json encoding accepted")
+	}
+
+	log.StartSync()
+	log.Warning("first\n")
+	f, _ := NewPatternFormatter("%lv:%msg")
+	if err = log.SetFormatter("file", f); err != nil {
+		t.Fatalf("set formatter failed, err = %s", err)
+	}
+	if err = log.SetFormatter("none", f); err == nil {
+		t.Fatalf("set formatter on unknown sink succeeded")
+	}
+	log.Error("second")
+	log.Stop()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "fmt_*.log"))
+	if len(files) != 1 {
+		t.Fatalf("log files not match, got = %v", files)
+	}
+	b, _ := os.ReadFile(files[0])
+	if got := string(b); got != "WARN|first\nE:second\n" {
+		t.Fatalf("file content not match, got = %q", got)
+	}
+}
+
+// logWrapper 模拟SimpleNet.logMsg这样的封装
+func logWrapper(log *Log, msg string) {
+	log.Output(1, LevelNotice, msg)
+}
+
+func TestCaller(t *testing.T) {
+	sink := &memLogger{name: sinkName(t, "mem_caller")}
+	if err := Register(sink); err != nil {
+		t.Fatalf("register failed, err = %s", err)
+	}
+
+	log := New("caller")
+	if _, err := log.AddSink(sink.name, "{}"); err != nil {
+		t.Fatalf("add sink failed, err = %s", err)
+	}
+	log.StartSync()
+	log.Info("no caller")
+	log.SetCaller(CallerFile | CallerGoID)
+	_, _, line, _ := runtime.Caller(0)
+	log.Info("format")
+	log.With("k", "v").Warning("entry")
+	logWrapper(log, "wrapper")
+	log.Output(0, LevelError, "output")
+	log.Stop()
+
+	msgs := sink.messages()
+	if len(msgs) != 5 {
+		t.Fatalf("messages not match, got = %q", msgs)
+	}
+	if strings.Contains(msgs[0], "logging_test.go") || strings.Contains(msgs[0], "[g") {
+		t.Fatalf("caller recorded without SetCaller, got = %q", msgs[0])
+	}
+	for i, msg := range msgs[1:] {
+		want := fmt.Sprintf(" logging_test.go:%d ", line+1+i)
+		if !strings.Contains(msg, want) || !strings.Contains(msg, "][g") {
+			t.Fatalf("caller not match, got = %q, want = %q", msg, want)
+		}
+	}
+
+	f, _ := NewPatternFormatter("%file|%line|%func|%gid")
+	msg := &Message{GoID: 9}
+	msg.PC, _, _, _ = runtime.Caller(0)
+	if got := string(f.Format(nil, msg)); !strings.HasPrefix(got, "logging_test.go|") ||
+		!strings.HasSuffix(got, "|logging.TestCaller|9\n") {
+		t.Fatalf("pattern caller not match, got = %q", got)
+	}
+}
+
+type discardLogger struct {
+	formatter Formatter
+	buf       []byte
+}
+
+func (d *discardLogger) Name() string           { return "discard" }
+func (d *discardLogger) Open(conf string) error { return nil }
+func (d *discardLogger) Write(msg *Message) (int, error) {
+	d.buf = d.formatter.Format(d.buf[:0], msg)
+	return len(d.buf), nil
+}
+func (d *discardLogger) Close() error { return nil }
+func (d *discardLogger) Sync() error  { return nil }
+
+func BenchmarkCaller(b *testing.B) {
+	name := sinkName(b, "discard")
+	err := RegisterFactory(name, func() Loger { return &discardLogger{formatter: TextFormatter{}} })
+	if err != nil {
+		b.Fatalf("register failed, err = %s", err)
+	}
+	for _, c := range []struct {
+		name  string
+		flags int
+	}{
+		{"none", 0},
+		{"file", CallerFile},
+		{"file_goid", CallerFile | CallerGoID},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			log := New("bench")
+			if _, err := log.AddSink(name, "{}"); err != nil {
+				b.Fatalf("add sink failed, err = %s", err)
+			}
+			log.SetCaller(c.flags)
+			log.StartSync()
+			defer log.Stop()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				log.Info("request done, id = %d", i)
+			}
+		})
+	}
+}
+
+type nilErr struct{ msg string }
+
+func (e *nilErr) Error() string { return e.msg }
+
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("boom") }
+
+func TestJSONNilValue(t *testing.T) {
+	msg := &Message{
+		Time:  time.Now(),
+		Level: LevelError,
+		Msg:   "nil values",
+		Fields: []Field{
+			{"err", (*nilErr)(nil)},
+			{"ok", &nilErr{msg: "EOF"}},
+			{"stringer", panicStringer{}},
+		},
+	}
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(appendJSON(nil, msg), &line); err != nil {
+		t.Fatalf("line not json, err = %s", err)
+	}
+	if line["err"] != "<nil>" || line["ok"] != "EOF" || line["stringer"] != "%!v(PANIC=boom)" {
+		t.Fatalf("values not match, got = %v", line)
+	}
+	if got := string(appendText(nil, msg)); !strings.Contains(got, "err=<nil> ok=EOF") {
+		t.Fatalf("text not match, got = %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// sinkSet 一组输出，NewLogging创建的Log共享defaultSinks
+type sinkSet struct {
+	lock    sync.Mutex
+	sinks   map[string]Loger
+	pending map[string]struct{} // 已占用名字、正在Open的输出
+}
+
+var defaultSinks = newSinkSet()
+
+func newSinkSet() *sinkSet {
+	return &sinkSet{
+		sinks:   make(map[string]Loger),
+		pending: make(map[string]struct{}),
+	}
+}
+
+// reserve 占用名字，之后用add加入或release释放
+func (s *sinkSet) reserve(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.sinks[name]; ok {
+		return fmt.Errorf("sink %s exists", name)
+	}
+	if _, ok := s.pending[name]; ok {
+		return fmt.Errorf("sink %s exists", name)
+	}
+	s.pending[name] = struct{}{}
+	return nil
+}
+
+func (s *sinkSet) release(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.pending, name)
+}
+
+// add 加入已经reserve的输出
+func (s *sinkSet) add(name string, log Loger) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.pending, name)
+	s.sinks[name] = log
+}
+
+func (s *sinkSet) remove(name string) Loger {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	log, ok := s.sinks[name]
+	if ok {
+		delete(s.sinks, name)
+	}
+	return log
+}
+
+// each 按名字顺序遍历，持有锁，fn中不能再操作s
+func (s *sinkSet) each(fn func(name string, log Loger)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := make([]string, 0, len(s.sinks))
+	for name := range s.sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, s.sinks[name])
+	}
+}
+
+func (s *sinkSet) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.sinks)
+}
+
+// closeAll 关闭并清空
+func (s *sinkSet) closeAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for name, log := range s.sinks {
+		if err := log.Close(); err != nil {
+			fmt.Printf("log close failed, sink = %s, err = %s\n", name, err)
+		}
+		delete(s.sinks, name)
+	}
+}
+
+// AddSink 创建并打开name类型的输出，conf为该输出的json配置，只属于当前Log。
+// 输出的名字为类型名，同一类型添加多个时用AddSinkAs
+func (l *Log) AddSink(name string, conf string) (Loger, error) {
+	return l.AddSinkAs(name, name, conf)
+}
+
+// AddSinkAs 创建并打开name类型、名为instance的输出，如"error"、"access"两个file输出
+func (l *Log) AddSinkAs(instance string, name string, conf string) (Loger, error) {
+	// 先占用名字，Register注册的实例是共用的，重名时不能再Open或Close
+	if err := l.sinks.reserve(instance); err != nil {
+		return nil, err
+	}
+	log, err := newLoger(name)
+	if err == nil {
+		err = log.Open(conf)
+	}
+	if err != nil {
+		l.sinks.release(instance)
+		return nil, err
+	}
+	l.sinks.add(instance, log)
+	return log, nil
+}
+
+// RemoveSink 关闭并移除名为instance的输出
+func (l *Log) RemoveSink(instance string) error {
+	log := l.sinks.remove(instance)
+	if log == nil {
+		return fmt.Errorf("sink %s not found", instance)
+	}
+	return log.Close()
+}
+
+// Sinks 当前Log的输出名字
+func (l *Log) Sinks() []string {
+	var names []string
+	l.sinks.each(func(name string, log Loger) {
+		names = append(names, name)
+	})
+	return names
+}
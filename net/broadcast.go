@@ -0,0 +1,147 @@
+package net
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+func sortConns(conns []*Connection) []*Connection {
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].id < conns[j].id
+	})
+	return conns
+}
+
+// GetConnection 按ID查找连接，包括主动连接和accept的连接，不存在返回nil
+func (n *SimpleNet) GetConnection(id int64) *Connection {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.conns[id]
+}
+
+// Connections 所有连接的快照，按ID排序
+func (n *SimpleNet) Connections() []*Connection {
+	n.lock.RLock()
+	conns := make([]*Connection, 0, len(n.conns))
+	for _, conn := range n.conns {
+		conns = append(conns, conn)
+	}
+	n.lock.RUnlock()
+	return sortConns(conns)
+}
+
+// ConnectionCount 连接数
+func (n *SimpleNet) ConnectionCount() int {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return len(n.conns)
+}
+
+// Connections Listener下所有连接的快照，按ID排序
+func (l *Listener) Connections() []*Connection {
+	l.lock.Lock()
+	conns := make([]*Connection, 0, len(l.conns))
+	for _, conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	l.lock.Unlock()
+	return sortConns(conns)
+}
+
+// ConnectionCount Listener下的连接数
+func (l *Listener) ConnectionCount() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.conns)
+}
+
+// msgCache 同一个proto只序列化一次，msgCache持有每个msg的一个引用，get返回的引用归调用方
+type msgCache struct {
+	protos []IProto
+	msgs   []*outMsg
+}
+
+func (c *msgCache) release() {
+	for _, msg := range c.msgs {
+		msg.release()
+	}
+}
+
+func (c *msgCache) get(conn *Connection, data interface{}) (*outMsg, error) {
+	proto := conn.proto
+	cacheable := proto == nil || reflect.TypeOf(proto).Comparable()
+	if cacheable {
+		for i, p := range c.protos {
+			if p == proto {
+				c.msgs[i].retain()
+				return c.msgs[i], nil
+			}
+		}
+	}
+	msg, err := serialize(proto, data, &conn.sizeHint)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable {
+		msg.retain()
+		c.protos = append(c.protos, proto)
+		c.msgs = append(c.msgs, msg)
+	}
+	return msg, nil
+}
+
+// fanout 向conns发送data，跳过不可发送及发送队列满的连接，返回成功放入发送队列的连接数
+func (n *SimpleNet) fanout(conns []*Connection, data interface{}) (int, error) {
+	var cache msgCache
+	defer cache.release()
+	sent := 0
+	for _, conn := range conns {
+		if conn.checkSend() != nil {
+			continue
+		}
+		msg, err := cache.get(conn, data)
+		if err != nil {
+			return sent, err
+		}
+		// 不等待慢连接
+		if n.sendMsg(context.Background(), conn, msg, false) == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Broadcast 向listen下filter返回true的连接发送data，filter为nil时发送给所有连接，
+// listen为nil时发送给所有连接。data按proto只序列化一次，返回成功放入发送队列的连接数
+func (n *SimpleNet) Broadcast(listen *Listener, data interface{}, filter func(conn *Connection) bool) (int, error) {
+	var conns []*Connection
+	if listen != nil {
+		conns = listen.Connections()
+	} else {
+		conns = n.Connections()
+	}
+	if filter != nil {
+		match := conns[:0]
+		for _, conn := range conns {
+			if filter(conn) {
+				match = append(match, conn)
+			}
+		}
+		conns = match
+	}
+	return n.fanout(conns, data)
+}
+
+// Multicast 向ids对应的连接发送data，不存在的ID被忽略，返回成功放入发送队列的连接数
+func (n *SimpleNet) Multicast(ids []int64, data interface{}) (int, error) {
+	conns := make([]*Connection, 0, len(ids))
+	n.lock.RLock()
+	for _, id := range ids {
+		if conn, ok := n.conns[id]; ok {
+			conns = append(conns, conn)
+		}
+	}
+	n.lock.RUnlock()
+	return n.fanout(conns, data)
+}
@@ -0,0 +1,122 @@
+package net
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countProto 统计序列化次数
+type countProto struct {
+	lenProto
+	count int32
+}
+
+func (p *countProto) Serialize(data interface{}) ([]byte, error) {
+	atomic.AddInt32(&p.count, 1)
+	return p.lenProto.Serialize(data)
+}
+
+func TestBroadcast(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	proto := &countProto{}
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0", proto,
+		&Options{Handler: server})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	const count = 5
+	var clients []*testHandler
+	var peers []*Connection
+	for i := 0; i < count; i++ {
+		client := newTestHandler()
+		conn, err := n.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, client)
+		if err != nil {
+			t.Fatalf("connect failed, err = %s", err)
+		}
+		if n.GetConnection(conn.ID()) != conn {
+			t.Fatalf("GetConnection not found")
+		}
+		clients = append(clients, client)
+		select {
+		case peer := <-server.connect:
+			peers = append(peers, peer)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("server OnConnect timeout")
+		}
+	}
+	if c := listen.ConnectionCount(); c != count {
+		t.Fatalf("listener connection count = %d, expect %d", c, count)
+	}
+	if c := n.ConnectionCount(); c != count*2 {
+		t.Fatalf("connection count = %d, expect %d", c, count*2)
+	}
+	conns := listen.Connections()
+	for i := 1; i < len(conns); i++ {
+		if conns[i-1].ID() >= conns[i].ID() {
+			t.Fatalf("connections not sorted by id")
+		}
+	}
+	if n.GetConnection(-1) != nil {
+		t.Fatalf("GetConnection should return nil")
+	}
+
+	// 跳过第一个连接
+	skip := peers[0]
+	sent, err := n.Broadcast(listen, []byte("broadcast"), func(conn *Connection) bool {
+		return conn != skip
+	})
+	if err != nil || sent != count-1 {
+		t.Fatalf("broadcast sent = %d, err = %v", sent, err)
+	}
+	if c := atomic.LoadInt32(&proto.count); c != 1 {
+		t.Fatalf("serialize count = %d, expect 1", c)
+	}
+	for i, client := range clients[1:] {
+		select {
+		case b := <-client.data:
+			if string(b) != "broadcast" {
+				t.Fatalf("client %d data not match, got = %s", i+1, b)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("client %d receive timeout", i+1)
+		}
+	}
+	select {
+	case <-clients[0].data:
+		t.Fatalf("filtered client should not receive")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	atomic.StoreInt32(&proto.count, 0)
+	sent, err = n.Multicast([]int64{peers[0].ID(), peers[2].ID(), -1}, []byte("multicast"))
+	if err != nil || sent != 2 {
+		t.Fatalf("multicast sent = %d, err = %v", sent, err)
+	}
+	if c := atomic.LoadInt32(&proto.count); c != 1 {
+		t.Fatalf("serialize count = %d, expect 1", c)
+	}
+	for _, i := range []int{0, 2} {
+		select {
+		case b := <-clients[i].data:
+			if string(b) != "multicast" {
+				t.Fatalf("client %d data not match, got = %s", i, b)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("client %d receive timeout", i)
+		}
+	}
+
+	n.CloseConn(peers[1])
+	if n.GetConnection(peers[1].ID()) != nil {
+		t.Fatalf("closed connection should be removed")
+	}
+	if c := listen.ConnectionCount(); c != count-1 {
+		t.Fatalf("listener connection count = %d, expect %d", c, count-1)
+	}
+}
@@ -0,0 +1,95 @@
+package net
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// 缓冲池按2的幂分级，64B到1M，超过1M直接分配不回收
+const (
+	minBufferClass = 6
+	maxBufferClass = 20
+
+	defSerializeSize = 512
+)
+
+// bufferPools 存放底层数组首地址，指针放入interface不需要额外分配
+var bufferPools [maxBufferClass + 1]sync.Pool
+
+// GetBuffer 从缓冲池取长度为size的[]byte，内容未初始化
+func GetBuffer(size int) []byte {
+	class := minBufferClass
+	if size > 1<<minBufferClass {
+		class = bits.Len(uint(size - 1))
+	}
+	if class > maxBufferClass {
+		return make([]byte, size)
+	}
+	if v := bufferPools[class].Get(); v != nil {
+		return unsafe.Slice((*byte)(v.(unsafe.Pointer)), 1<<class)[:size]
+	}
+	return make([]byte, size, 1<<class)
+}
+
+// PutBuffer 回收缓冲，之后不能再使用b，b不要求来自GetBuffer
+func PutBuffer(b []byte) {
+	c := cap(b)
+	if c < 1<<minBufferClass {
+		return
+	}
+	class := bits.Len(uint(c)) - 1
+	if class > maxBufferClass {
+		return
+	}
+	// 按不超过容量的级别回收，保证取出时容量足够
+	bufferPools[class].Put(unsafe.Pointer(unsafe.SliceData(b[:1])))
+}
+
+// IBufferProto proto可选实现，读写都使用缓冲池
+type IBufferProto interface {
+	// SerializeTo 把data序列化追加到buf后返回，buf来自缓冲池，发送完成后由框架回收
+	SerializeTo(buf []byte, data interface{}) ([]byte, error)
+	// RetainBody Parse的结果是否引用body，返回false时Parse之后立即回收body，
+	// 返回true时body随事件交给使用方，由ConnEvent.Release回收
+	RetainBody() bool
+}
+
+func retainBody(proto IProto) bool {
+	if p, ok := proto.(IBufferProto); ok {
+		return p.RetainBody()
+	}
+	return true
+}
+
+// Release 回收事件数据引用的读缓冲，之后不能再使用Data。
+// 不调用时缓冲由GC回收；回调模式下Options.ReleaseData为true时OnData返回后自动回收
+func (e *ConnEvent) Release() {
+	if e.buf != nil {
+		PutBuffer(e.buf)
+		e.buf = nil
+	}
+}
+
+// outMsg 发送队列中的数据，广播时多个连接共享同一个outMsg
+type outMsg struct {
+	data   []byte
+	pooled bool // data来自缓冲池，引用计数为0时回收
+	refs   int32
+}
+
+func newOutMsg(data []byte, pooled bool) *outMsg {
+	return &outMsg{data: data, pooled: pooled, refs: 1}
+}
+
+func (m *outMsg) retain() {
+	atomic.AddInt32(&m.refs, 1)
+}
+
+func (m *outMsg) release() {
+	if atomic.AddInt32(&m.refs, -1) == 0 && m.pooled {
+		PutBuffer(m.data)
+		m.data = nil
+	}
+}
@@ -0,0 +1,154 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBufferPool(t *testing.T) {
+	for _, size := range []int{0, 1, 64, 65, 1000, 4096, 1 << 20} {
+		b := GetBuffer(size)
+		if len(b) != size || cap(b) < size {
+			t.Fatalf("GetBuffer(%d) len = %d, cap = %d", size, len(b), cap(b))
+		}
+		PutBuffer(b)
+	}
+	// 超过最大级别直接分配
+	if b := GetBuffer(1<<20 + 1); len(b) != 1<<20+1 {
+		t.Fatalf("large buffer len = %d", len(b))
+	}
+	// 非2的幂容量按较小级别回收，再取出时容量仍然足够
+	PutBuffer(make([]byte, 100))
+	for i := 0; i < 16; i++ {
+		if b := GetBuffer(64); cap(b) < 64 {
+			t.Fatalf("buffer cap = %d, expect >= 64", cap(b))
+		}
+	}
+}
+
+// bufProto 使用缓冲池的lenProto，Parse直接返回body
+type bufProto struct {
+	lenProto
+}
+
+func (p *bufProto) SerializeTo(buf []byte, data interface{}) ([]byte, error) {
+	body := data.([]byte)
+	buf = binary.BigEndian.AppendUint32(buf, (uint32)(len(body)))
+	return append(buf, body...), nil
+}
+func (p *bufProto) RetainBody() bool {
+	return true
+}
+
+func TestBufferProto(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.Listen("127.0.0.1:0", &bufProto{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	const clients, count = 3, 200
+	var handlers []*testHandler
+	for i := 0; i < clients; i++ {
+		h := newTestHandler()
+		h.data = make(chan []byte, count)
+		_, err := n.ConnectContext(context.Background(), listen.LocalAddress(), &bufProto{},
+			&Options{Handler: h})
+		if err != nil {
+			t.Fatalf("connect failed, err = %s", err)
+		}
+		handlers = append(handlers, h)
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for listen.ConnectionCount() != clients {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection count = %d", listen.ConnectionCount())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// 广播共享同一个池化的包，全部发送完才回收
+	for i := 0; i < count; i++ {
+		sent, err := n.Broadcast(listen, []byte(fmt.Sprintf("broadcast %d", i)), nil)
+		if err != nil || sent != clients {
+			t.Fatalf("broadcast sent = %d, err = %v", sent, err)
+		}
+	}
+	for c, h := range handlers {
+		for i := 0; i < count; i++ {
+			select {
+			case b := <-h.data:
+				if expect := fmt.Sprintf("broadcast %d", i); string(b) != expect {
+					t.Fatalf("client %d data = %s, expect %s", c, b, expect)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatalf("client %d receive %d timeout", c, i)
+			}
+		}
+	}
+}
+
+// benchmarkReadWrite PollEvent消费，pooled为true时使用缓冲池并回收读缓冲
+func benchmarkReadWrite(b *testing.B, proto IProto, release bool) {
+	quiet(b)
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.Listen("127.0.0.1:0", proto)
+	if err != nil {
+		b.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), proto,
+		&Options{Handler: &NopHandler{}})
+	if err != nil {
+		b.Fatalf("connect failed, err = %s", err)
+	}
+
+	var received int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for atomic.LoadInt64(&received) < (int64)(b.N) {
+			evt, err := n.PollEvent(1000)
+			if err != nil {
+				return
+			}
+			if evt.EventType == EventNewConnectionData {
+				atomic.AddInt64(&received, 1)
+				if release {
+					evt.Release()
+				}
+			}
+		}
+	}()
+
+	body := make([]byte, 1024)
+	b.SetBytes((int64)(len(body) + 4))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = n.SendData(conn, body); err != nil {
+			b.Fatalf("send failed, err = %s", err)
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second * 30):
+		b.Fatalf("receive timeout, count = %d", atomic.LoadInt64(&received))
+	}
+}
+
+// BenchmarkReadWrite 对比每包分配与缓冲池的内存分配
+func BenchmarkReadWrite(b *testing.B) {
+	b.Run("alloc", func(b *testing.B) {
+		benchmarkReadWrite(b, &lenProto{}, false)
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkReadWrite(b, &bufProto{}, true)
+	})
+}
@@ -0,0 +1,100 @@
+package net
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ICorrelator proto可选实现，支持Call请求应答匹配
+type ICorrelator interface {
+	// SetCorrelation 把请求ID写入req，id从1开始
+	SetCorrelation(req interface{}, id uint64)
+	// Correlation 返回应答对应的请求ID，ok为false表示不是应答，
+	// 没有等待中的Call时按普通数据事件投递
+	Correlation(data interface{}) (id uint64, ok bool)
+}
+
+// pendingCall 等待应答的请求
+type pendingCall struct {
+	rsp chan interface{}
+	err chan error
+}
+
+// Call 发送请求并等待对应的应答，proto需实现ICorrelator。
+// ctx结束返回ctx.Err()，等待期间连接关闭返回ErrNotConnected，
+// 断线重连时旧socket上的请求收不到应答，返回ErrReconnecting
+func (n *SimpleNet) Call(ctx context.Context, conn *Connection, req interface{}) (interface{}, error) {
+	c, ok := conn.proto.(ICorrelator)
+	if !ok {
+		return nil, ErrCallUnsupported
+	}
+	id := atomic.AddUint64(&conn.nextCall, 1)
+	c.SetCorrelation(req, id)
+
+	call := &pendingCall{
+		rsp: make(chan interface{}, 1),
+		err: make(chan error, 1),
+	}
+	conn.callLock.Lock()
+	if conn.calls == nil {
+		conn.calls = make(map[uint64]*pendingCall)
+	}
+	conn.calls[id] = call
+	conn.callLock.Unlock()
+	defer conn.removeCall(id)
+
+	if err := n.SendDataContext(ctx, conn, req); err != nil {
+		return nil, err
+	}
+	select {
+	case rsp := <-call.rsp:
+		return rsp, nil
+	case err := <-call.err:
+		return nil, err
+	case <-conn.closed:
+		return nil, ErrNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Connection) removeCall(id uint64) *pendingCall {
+	c.callLock.Lock()
+	defer c.callLock.Unlock()
+
+	call, ok := c.calls[id]
+	if ok {
+		delete(c.calls, id)
+	}
+	return call
+}
+
+// failCalls 所有等待中的Call返回err
+func (c *Connection) failCalls(err error) {
+	c.callLock.Lock()
+	defer c.callLock.Unlock()
+
+	for id, call := range c.calls {
+		call.err <- err
+		delete(c.calls, id)
+	}
+}
+
+// handleResponse 应答交给等待的Call，返回true表示data已被处理
+func (n *SimpleNet) handleResponse(conn *Connection, data interface{}) bool {
+	c, ok := conn.proto.(ICorrelator)
+	if !ok {
+		return false
+	}
+	id, ok := c.Correlation(data)
+	if !ok || id == 0 {
+		return false
+	}
+	call := conn.removeCall(id)
+	if call == nil {
+		// 已经超时或者不是Call发出的请求
+		return false
+	}
+	call.rsp <- data
+	return true
+}
@@ -0,0 +1,208 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type rpcMsg struct {
+	id   uint64
+	rsp  bool
+	body string
+}
+
+// rpcProto 4字节长度 + 8字节请求ID + 1字节应答标记 + 包体
+type rpcProto struct {
+	lenProto
+}
+
+func (p *rpcProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	if len(body) < 9 {
+		return nil, fmt.Errorf("body too short")
+	}
+	return &rpcMsg{
+		id:   binary.BigEndian.Uint64(body),
+		rsp:  body[8] == 1,
+		body: string(body[9:]),
+	}, nil
+}
+func (p *rpcProto) Serialize(data interface{}) ([]byte, error) {
+	m := data.(*rpcMsg)
+	body := binary.BigEndian.AppendUint64(nil, m.id)
+	if m.rsp {
+		body = append(body, 1)
+	} else {
+		body = append(body, 0)
+	}
+	return p.lenProto.Serialize(append(body, m.body...))
+}
+func (p *rpcProto) SetCorrelation(req interface{}, id uint64) {
+	req.(*rpcMsg).id = id
+}
+func (p *rpcProto) Correlation(data interface{}) (uint64, bool) {
+	m := data.(*rpcMsg)
+	return m.id, m.rsp
+}
+
+// rpcServer 应答大写的包体，drop不应答，close关闭连接
+type rpcServer struct {
+	NopHandler
+}
+
+func (h *rpcServer) OnData(conn *Connection, data interface{}) {
+	m := data.(*rpcMsg)
+	switch m.body {
+	case "drop":
+	case "close":
+		conn.Net().CloseConn(conn)
+	default:
+		conn.Net().SendData(conn, &rpcMsg{id: m.id, rsp: true, body: strings.ToUpper(m.body)})
+	}
+}
+
+type rpcClient struct {
+	NopHandler
+	data chan *rpcMsg
+}
+
+func (h *rpcClient) OnData(conn *Connection, data interface{}) {
+	h.data <- data.(*rpcMsg)
+}
+
+func TestCall(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &rpcProto{}, &rpcServer{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	client := &rpcClient{data: make(chan *rpcMsg, 16)}
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), &rpcProto{}, client)
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			body := fmt.Sprintf("call %d", i)
+			rsp, err := n.Call(ctx, conn, &rpcMsg{body: body})
+			if err != nil {
+				t.Errorf("call failed, err = %s", err)
+				return
+			}
+			if got := rsp.(*rpcMsg).body; got != strings.ToUpper(body) {
+				t.Errorf("response not match, got = %s, expect = %s", got, strings.ToUpper(body))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 超时
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err = n.Call(ctx, conn, &rpcMsg{body: "drop"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, err = %v", err)
+	}
+
+	// 不是Call发出的请求，应答按普通数据投递
+	n.SendData(conn, &rpcMsg{id: 0, body: "plain"})
+	select {
+	case m := <-client.data:
+		if m.body != "PLAIN" {
+			t.Fatalf("unsolicited data not match, got = %s", m.body)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("unsolicited data timeout")
+	}
+
+	// 等待期间连接断开
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if _, err = n.Call(ctx, conn, &rpcMsg{body: "close"}); err != ErrNotConnected {
+		t.Fatalf("expect ErrNotConnected, err = %v", err)
+	}
+
+	if _, err = n.Call(ctx, conn, &rpcMsg{}); err != ErrNotConnected {
+		t.Fatalf("call on closed connection, err = %v", err)
+	}
+}
+
+func TestCallUnsupported(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, &NopHandler{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, &NopHandler{})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	if _, err = n.Call(context.Background(), conn, []byte("x")); err != ErrCallUnsupported {
+		t.Fatalf("expect ErrCallUnsupported, err = %v", err)
+	}
+}
+
+func TestCallReconnect(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &rpcProto{}, &rpcServer{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), &rpcProto{},
+		&Options{
+			Handler: &rpcClient{data: make(chan *rpcMsg, 16)},
+			Reconnect: &ReconnectPolicy{
+				InitialInterval: time.Millisecond * 10,
+				MaxInterval:     time.Millisecond * 50,
+			},
+		})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+
+	// 等待期间断线重连，没有超时的ctx也不能一直阻塞
+	done := make(chan error, 1)
+	go func() {
+		_, err := n.Call(context.Background(), conn, &rpcMsg{body: "close"})
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		if err != ErrReconnecting {
+			t.Fatalf("expect ErrReconnecting, err = %v", err)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatalf("call blocked across reconnect")
+	}
+
+	// 重连后可以继续Call
+	deadline := time.Now().Add(time.Second * 5)
+	for conn.Status() != StatusConnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("reconnect timeout, status = %d", conn.Status())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	rsp, err := n.Call(ctx, conn, &rpcMsg{body: "again"})
+	if err != nil || rsp.(*rpcMsg).body != "AGAIN" {
+		t.Fatalf("call after reconnect failed, rsp = %v, err = %v", rsp, err)
+	}
+}
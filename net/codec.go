@@ -0,0 +1,114 @@
+package net
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec FrameProto的包体编解码
+type Codec interface {
+	// Encode 把v编码追加到buf后返回
+	Encode(buf []byte, v interface{}) ([]byte, error)
+	// Decode 解码包体
+	Decode(body []byte) (interface{}, error)
+	// RetainBody Decode的结果是否引用body，同IBufferProto
+	RetainBody() bool
+}
+
+// RawCodec 包体不做编解码，发送[]byte或string，收到[]byte
+type RawCodec struct{}
+
+// Encode Codec
+func (RawCodec) Encode(buf []byte, v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return append(buf, b...), nil
+	case string:
+		return append(buf, b...), nil
+	}
+	return nil, fmt.Errorf("raw codec: unsupported type %T", v)
+}
+
+// Decode Codec，返回body本身
+func (RawCodec) Decode(body []byte) (interface{}, error) {
+	return body, nil
+}
+
+// RetainBody Codec
+func (RawCodec) RetainBody() bool {
+	return true
+}
+
+// JSONCodec json编解码
+type JSONCodec struct {
+	// New 返回解码的目标，一般是结构体指针，nil时解码成map[string]interface{}等通用类型
+	New func() interface{}
+}
+
+// Encode Codec
+func (c *JSONCodec) Encode(buf []byte, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, b...), nil
+}
+
+// Decode Codec
+func (c *JSONCodec) Decode(body []byte) (interface{}, error) {
+	if c.New == nil {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	v := c.New()
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// RetainBody Codec
+func (c *JSONCodec) RetainBody() bool {
+	return false
+}
+
+// ProtoCodec protobuf编解码
+type ProtoCodec struct {
+	// New 返回解码的目标消息，每次调用返回新的实例
+	New func() proto.Message
+}
+
+// Encode Codec
+func (c *ProtoCodec) Encode(buf []byte, v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("proto codec: unsupported type %T", v)
+	}
+	pb := proto.NewBuffer(buf)
+	if err := pb.Marshal(m); err != nil {
+		return nil, err
+	}
+	return pb.Bytes(), nil
+}
+
+// Decode Codec
+func (c *ProtoCodec) Decode(body []byte) (interface{}, error) {
+	if c.New == nil {
+		return nil, fmt.Errorf("proto codec: New not set")
+	}
+	m := c.New()
+	if err := proto.Unmarshal(body, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RetainBody Codec，proto.Unmarshal会拷贝数据
+func (c *ProtoCodec) RetainBody() bool {
+	return false
+}
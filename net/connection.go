@@ -1,6 +1,9 @@
 package net
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -19,6 +22,9 @@ const (
 	EventNewConnectionData
 	EventProtoError
 	EventTimeout
+	EventReconnecting
+	EventReconnected
+	EventIdle
 )
 
 const (
@@ -26,28 +32,53 @@ const (
 	StatusListenning
 	StatusConnected
 	StatusBroken
+	StatusReconnecting
 )
 
 type ConnEvent struct {
 	EventType int
 	Conn      *Connection
 	Data      interface{}
+
+	buf []byte // Data引用的读缓冲，见Release
 }
 
 type Connection struct {
 	net    *SimpleNet
 	listen *Listener
 
-	id      int64
-	status  int64
-	conn    net.Conn
-	msgChan chan []byte
+	id       int64
+	status   int64 // 原子读写，迁移见casStatus
+	lock     sync.Mutex
+	conn     net.Conn
+	sockDone chan struct{}
+	queue    *sendQueue // 不关闭，写协程通过sockDone、flush退出
+	flush    chan struct{}
+	closed   chan struct{}
+	cause    error // 主动关闭的原因，conn.lock保护
+	notified int32 // 关闭事件已通知
+	writes   int64 // 写系统调用次数
+	stat     counters
+	connTime int64 // 连接(或重连)成功的时间(UnixNano)
+	sizeHint int64 // 上次序列化的长度
+
+	callLock sync.Mutex
+	calls    map[uint64]*pendingCall
+	nextCall uint64
+
+	network string
+	addr    string
+	ctx     context.Context
 
 	localAddr  string
 	remoteAddr string
-	upTime     time.Time
+	readTime   int64 // 最后读到数据的时间(UnixNano)
+	writeTime  int64 // 最后写出数据的时间(UnixNano)
+	idle       idleConfig
 
 	proto    IProto // 为了实现多种proto
+	opt      *Options
+	handler  handlerValue
 	UserData interface{}
 }
 
@@ -59,31 +90,61 @@ func (c *Connection) ID() int64 {
 }
 
 func (c *Connection) Status() int64 {
-	return c.status
+	return atomic.LoadInt64(&c.status)
 }
 
 func (c *Connection) LocalAddress() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.localAddr
 }
 
 func (c *Connection) RemoteAddress() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.remoteAddr
 }
 func (c *Connection) UpdateTime() time.Time {
-	return c.upTime
+	r, w := atomic.LoadInt64(&c.readTime), atomic.LoadInt64(&c.writeTime)
+	if w > r {
+		return time.Unix(0, w)
+	}
+	return time.Unix(0, r)
+}
+
+// ReadTime 最后读到数据的时间
+func (c *Connection) ReadTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.readTime))
+}
+
+// WriteTime 最后写出数据的时间
+func (c *Connection) WriteTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.writeTime))
+}
+
+// SetHandler 设置连接回调，覆盖所属Listener的回调
+func (c *Connection) SetHandler(h Handler) {
+	c.handler.store(h)
 }
 
 type Listener struct {
 	net *SimpleNet
 
-	id     int64
-	status int64
-	listen net.Listener
-	conns  []*Connection
+	id      int64
+	status  int64 // 原子读写
+	network string
+	listen  net.Listener
+	packet  net.PacketConn
+	closed  chan struct{}
+	stat    counters // 所有accept的连接的汇总，包括已关闭的
 
-	lockClient sync.Locker
+	lock  sync.Mutex // 保护peers、conns
+	peers map[string]*udpConn
+	conns map[int64]*Connection
 
 	proto    IProto
+	opt      *Options
+	handler  handlerValue
 	UserData interface{}
 }
 
@@ -93,30 +154,55 @@ func (l *Listener) ID() int64 {
 func (l *Listener) Net() *SimpleNet {
 	return l.net
 }
+
+// Status 监听状态
+func (l *Listener) Status() int64 {
+	return atomic.LoadInt64(&l.status)
+}
 func (l *Listener) LocalAddress() string {
+	if l.packet != nil {
+		return l.packet.LocalAddr().String()
+	}
 	return l.listen.Addr().String()
 }
 
+// SetHandler 设置Listener回调，之后accept的连接默认使用该回调
+func (l *Listener) SetHandler(h Handler) {
+	l.handler.store(h)
+}
+
 type SimpleNet struct {
 	events chan *ConnEvent
+	done   chan struct{} // Shutdown等待协程退出前关闭，阻塞在派发事件的协程丢弃事件退出
 
-	connClient []*Connection
-	connServer []*Listener
+	lock    sync.RWMutex // 保护conns、listens及closing的设置
+	conns   map[int64]*Connection
+	listens map[int64]*Listener
 
-	lockServer sync.Locker
-	lockClient sync.Locker
+	nextid   int64
+	closing  int32
+	outbound counters // 主动连接的汇总计数
 
-	nextid  int64
-	destroy bool
+	wg       sync.WaitGroup // 读写、空闲检查、重连协程
+	writers  sync.WaitGroup // 写协程，Shutdown等待发送队列清空
+	acceptWg sync.WaitGroup // accept协程
+
+	lockPool    sync.Mutex
+	pool        *workerPool
+	poolWorkers int
+	poolQueue   int
+	poolClosed  bool
 
 	log *mylog.Log
 
 	UserData interface{}
 }
 
+// IProto 协议，可选实现IHeartbeat、IBufferProto、ICorrelator、IDecoder
 type IProto interface {
 	FilterAccept(conn *Connection) bool
 	HeadLen() uint32
+	// BodyLen head在读下一个包时复用，返回的头部信息不能引用head
 	BodyLen(head []byte) (interface{}, uint32, error)
 	Parse(head interface{}, body []byte) (interface{}, error)
 	Serialize(data interface{}) ([]byte, error)
@@ -125,244 +211,262 @@ type IProto interface {
 // NewSimpleNet 创建
 func NewSimpleNet(log *mylog.Log) *SimpleNet {
 	n := &SimpleNet{
-		events:     make(chan *ConnEvent, 1024),
-		lockServer: &sync.Mutex{},
-		lockClient: &sync.Mutex{},
-		log:        log,
+		events:  make(chan *ConnEvent, 1024),
+		done:    make(chan struct{}),
+		conns:   make(map[int64]*Connection),
+		listens: make(map[int64]*Listener),
+		log:     log,
 	}
 
 	return n
 }
 
+// SimpleNetDestroy 立即关闭，不等待发送队列
 func SimpleNetDestroy(n *SimpleNet) {
-	close(n.events)
-	for _, v := range n.connClient {
-		n.CloseConn(v)
-	}
-
-	for _, v := range n.connServer {
-		n.CloseListen(v)
-	}
-	n.destroy = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n.Shutdown(ctx)
 }
 
+// logMsg Log开启SetCaller时记录的是logMsg的调用位置
 func (n *SimpleNet) logMsg(level int, msg string) {
 	if n.log != nil {
-		switch level {
-		case mylog.LevelTrace:
-			n.log.Trace("%s", msg)
-		case mylog.LevelDebug:
-			n.log.Debug("%s", msg)
-		case mylog.LevelInformational:
-			n.log.Info("%s", msg)
-		case mylog.LevelNotice:
-			n.log.Notice("%s", msg)
-		case mylog.LevelWarning:
-			n.log.Warning("%s", msg)
-		case mylog.LevelError:
-			n.log.Error("%s", msg)
-		case mylog.LevelCritical:
-			n.log.Critical("%s", msg)
-		}
+		n.log.Output(1, (int64)(level), msg)
 		return
 	}
 	fmt.Printf("%s", msg)
 }
 
-func (n *SimpleNet) syncAddListen(listen *Listener) {
-	n.lockServer.Lock()
-	defer n.lockServer.Unlock()
-
-	n.connServer = append(n.connServer, listen)
-
-}
-func (n *SimpleNet) syncDelListen(listen *Listener) {
-	n.lockServer.Lock()
-	defer n.lockServer.Unlock()
-
-	for i, v := range n.connServer {
-		if v == listen {
-			if i == len(n.connServer)-1 {
-				n.connServer = n.connServer[:i]
-			} else {
-				n.connServer = append(n.connServer[:i], n.connServer[i+1:]...)
-			}
-			break
-		}
-	}
-
-}
-
-func (n *SimpleNet) syncAddClient(conn *Connection) {
-	var connQueue []*Connection
-
-	connQueue = n.connClient
-	lock := n.lockClient
-	if conn.listen != nil {
-		connQueue = conn.listen.conns
-		lock = conn.listen.lockClient
-	}
-
-	lock.Lock()
-	defer lock.Unlock()
-
-	connQueue = append(connQueue, conn)
-
-	if conn.listen != nil {
-		conn.listen.conns = connQueue
-	} else {
-		n.connClient = connQueue
-	}
-}
-func (n *SimpleNet) syncDelClient(conn *Connection) {
-	var connQueue []*Connection
-
-	connQueue = n.connClient
-	lock := n.lockClient
-	if conn.listen != nil {
-		connQueue = conn.listen.conns
-		lock = conn.listen.lockClient
-	}
-
-	lock.Lock()
-	defer lock.Unlock()
-
-	var del bool
-	for i, v := range connQueue {
-		if v == conn {
-			if i == len(connQueue)-1 {
-				connQueue = connQueue[:i]
-			} else {
-				connQueue = append(connQueue[:i], connQueue[i+1:]...)
-			}
-			del = true
-			break
-		}
-	}
-
-	if del {
-		if conn.listen != nil {
-			conn.listen.conns = connQueue
-		} else {
-			n.connClient = connQueue
-		}
-	}
-}
-
-func (n *SimpleNet) checkConnErr(count int, err error, conn *Connection) error {
+func (n *SimpleNet) checkConnErr(count int, err error, conn *Connection, sock net.Conn) error {
 	if err != nil {
 		n.logMsg(mylog.LevelError, fmt.Sprintf("conn err = %s\n", err))
-		if conn.net.destroy {
+		if n.isClosing() {
 			n.logMsg(mylog.LevelError, fmt.Sprintf("net destroy\n"))
 			return err
 		}
-		if conn.status == StatusConnected {
-			close(conn.msgChan)
-			conn.conn.Close()
-			conn.status = StatusBroken
+		conn.lock.Lock()
+		if conn.conn != sock || conn.Status() == StatusReconnecting {
+			// 旧socket的读写协程，或者已经在重连
+			conn.lock.Unlock()
+			return err
+		}
+		if n.startReconnect(conn, err) {
+			conn.lock.Unlock()
+			return err
+		}
+		conn.lock.Unlock()
 
-			n.syncDelClient(conn)
+		if !n.breakConn(conn) {
+			// 已经被主动关闭，通知关闭的原因，只需要通知一次
+			if cause := conn.closeCause(); cause != nil {
+				err = cause
+			}
 		}
+
 		evt := EventConnectionError
-		if err == io.EOF {
+		if err == io.EOF || errors.Is(err, net.ErrClosed) {
 			evt = EventConnectionClosed
 		}
 		n.logMsg(mylog.LevelDebug, fmt.Sprintf("event type %d\n", evt))
 
 		// emit EventConnectionError
-		event := &ConnEvent{
-			EventType: evt,
-			Conn:      conn,
-			Data:      err,
-		}
-		n.events <- event
+		n.notifyClosed(context.Background(), conn, evt, err)
 	}
 	return err
 }
-func (n *SimpleNet) handleRead(conn *Connection) {
+func (n *SimpleNet) handleRead(conn *Connection, sock net.Conn) {
 	defer func() {
 		err := recover()
 		if err != nil {
 			n.logMsg(mylog.LevelError, fmt.Sprintf("handleRead panic: %s\n", err))
 		}
 	}()
+	in := &countReader{conn: conn, r: sock}
+	if proto, ok := conn.proto.(*FrameProto); ok {
+		n.readFramed(conn, sock, in, proto)
+		return
+	}
+	if dec, ok := conn.proto.(IDecoder); ok {
+		n.readStream(conn, sock, in, dec)
+		return
+	}
+	headlen := (uint32)(0)
+	if conn.proto != nil {
+		headlen = conn.proto.HeadLen()
+	}
+	if headlen <= 0 {
+		n.readRaw(conn, sock, in)
+		return
+	}
+	n.readFrame(conn, sock, in, headlen)
+}
+
+// readRaw 没有proto时，每次读到多少数据就投递多少
+func (n *SimpleNet) readRaw(conn *Connection, sock net.Conn, in io.Reader) {
+	size := conn.readBufferSize()
 	for {
-		headlen := (uint32)(0)
-		if conn.proto != nil {
-			headlen = conn.proto.HeadLen()
-		}
-		if headlen <= 0 {
-			buf := make([]byte, 1)
-			count, err := conn.conn.Read(buf)
-			if err = n.checkConnErr(count, err, conn); err != nil {
-				return
-			}
+		buf := GetBuffer(size)
+		count, err := in.Read(buf)
+		if count > 0 {
 			n.logMsg(mylog.LevelInformational,
 				fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
-					count, conn.conn.RemoteAddr()))
+					count, sock.RemoteAddr()))
 
 			// emit
 			event := &ConnEvent{
 				EventType: EventNewConnectionData,
 				Conn:      conn,
-				Data:      buf,
+				Data:      buf[:count],
+				buf:       buf,
 			}
-			n.events <- event
-
+			conn.addFrameIn()
+			n.emit(event)
+			conn.touchRead()
 		} else {
-			head := make([]byte, headlen)
-			count, err := conn.conn.Read(head)
-			if err = n.checkConnErr(count, err, conn); err != nil {
+			PutBuffer(buf)
+		}
+		if err = n.checkConnErr(count, err, conn, sock); err != nil {
+			return
+		}
+	}
+}
+
+// readFrame 按proto读取完整的头部和包体
+func (n *SimpleNet) readFrame(conn *Connection, sock net.Conn, in io.Reader, headlen uint32) {
+	maxFrame := conn.opt.maxFrameSize()
+	// 头部从缓冲中Peek，解析失败时可以从头部中重新对齐
+	reader := bufio.NewReaderSize(in, max(conn.readBufferSize(), (int)(headlen)))
+	retain := retainBody(conn.proto)
+	for {
+		head, err := reader.Peek((int)(headlen))
+		if err = n.checkConnErr(len(head), err, conn, sock); err != nil {
+			return
+		}
+		headmsg, bodylen, err := conn.proto.BodyLen(head)
+		if err == nil && bodylen > maxFrame {
+			err = fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, bodylen, maxFrame)
+		} else if err != nil && !errors.Is(err, ErrBadHeader) {
+			err = fmt.Errorf("%w: %w", ErrBadHeader, err)
+		}
+		if err != nil {
+			if !n.protoError(conn, sock, reader, err, (int)(headlen)) {
 				return
 			}
-			n.logMsg(mylog.LevelInformational,
-				fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
-					count, conn.conn.RemoteAddr()))
-			headmsg, bodylen, err := conn.proto.BodyLen(head)
-			if err != nil {
-				// emit EventConnectionError
-				event := &ConnEvent{
-					EventType: EventProtoError,
-					Conn:      conn,
-					Data:      err,
-				}
-				n.events <- event
-				continue
+			continue
+		}
+		reader.Discard((int)(headlen))
+
+		body := GetBuffer((int)(bodylen))
+		count, err := io.ReadFull(reader, body)
+		if err = n.checkConnErr(count, err, conn, sock); err != nil {
+			return
+		}
+		conn.touchRead()
+		n.logMsg(mylog.LevelInformational,
+			fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
+				(int)(headlen)+count, sock.RemoteAddr()))
+
+		data, err := conn.proto.Parse(headmsg, body)
+		if err != nil || !retain {
+			PutBuffer(body)
+			body = nil
+		}
+		if err != nil {
+			if !n.protoError(conn, sock, reader, err, 0) {
+				return
 			}
+			continue
+		}
+		n.dispatch(conn, data, body)
+	}
+}
 
-			body := make([]byte, bodylen)
-			count, err = conn.conn.Read(body)
-			if err = n.checkConnErr(count, err, conn); err != nil {
+// readFramed FrameProto由Framer读取包体
+func (n *SimpleNet) readFramed(conn *Connection, sock net.Conn, in io.Reader, proto *FrameProto) {
+	maxFrame := conn.opt.maxFrameSize()
+	reader := bufio.NewReaderSize(in, conn.readBufferSize())
+	retain := proto.RetainBody()
+	for {
+		body, err := proto.Framer.ReadFrame(reader, maxFrame)
+		if err != nil {
+			if errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrBadHeader) {
+				// 分帧错误，后续数据无法对齐，只能断开
+				n.emitProtoError(conn, err)
+			}
+			n.checkConnErr(0, err, conn, sock)
+			return
+		}
+		conn.touchRead()
+		n.logMsg(mylog.LevelInformational,
+			fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
+				len(body), sock.RemoteAddr()))
+
+		data, err := proto.Parse(nil, body)
+		if err != nil || !retain {
+			PutBuffer(body)
+			body = nil
+		}
+		if err != nil {
+			if !n.protoError(conn, sock, reader, err, 0) {
 				return
 			}
+			continue
+		}
+		n.dispatch(conn, data, body)
+	}
+}
+
+// readStream IDecoder自行解码，一次可以得到多个消息
+func (n *SimpleNet) readStream(conn *Connection, sock net.Conn, in io.Reader, dec IDecoder) {
+	maxFrame := conn.opt.maxFrameSize()
+	reader := bufio.NewReaderSize(in, conn.readBufferSize())
+	for {
+		msgs, err := dec.Decode(reader, maxFrame)
+		if len(msgs) > 0 {
+			conn.touchRead()
 			n.logMsg(mylog.LevelInformational,
-				fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
-					count, conn.conn.RemoteAddr()))
-
-			data, err := conn.proto.Parse(headmsg, body)
-			if err != nil {
-				// emit EventConnectionError
-				event := &ConnEvent{
-					EventType: EventProtoError,
-					Conn:      conn,
-					Data:      err,
-				}
-				n.events <- event
-				continue
-			}
-			// emit EventNewConnectionData
-			event := &ConnEvent{
-				EventType: EventNewConnectionData,
-				Conn:      conn,
-				Data:      data,
+				fmt.Sprintf("read data, messages = %d, remoteAddr: = %s\n",
+					len(msgs), sock.RemoteAddr()))
+		}
+		// 出错前已经解码的消息照常投递
+		for _, data := range msgs {
+			n.dispatch(conn, data, nil)
+		}
+		if err != nil {
+			if errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrBadHeader) {
+				n.emitProtoError(conn, err)
 			}
-			n.events <- event
+			n.checkConnErr(0, err, conn, sock)
+			return
 		}
-		conn.upTime = time.Now()
 	}
 }
 
-func (n *SimpleNet) handleWrite(conn *Connection) {
+// dispatch 处理心跳和Call应答，其余作为数据事件投递，body随事件交给使用方
+func (n *SimpleNet) dispatch(conn *Connection, data interface{}, body []byte) {
+	conn.addFrameIn()
+	if n.handleHeartbeat(conn, data) {
+		if body != nil {
+			PutBuffer(body)
+		}
+		return
+	}
+	if n.handleResponse(conn, data) {
+		// body可能被应答引用，不回收
+		return
+	}
+	// emit EventNewConnectionData
+	event := &ConnEvent{
+		EventType: EventNewConnectionData,
+		Conn:      conn,
+		Data:      data,
+		buf:       body,
+	}
+	n.emit(event)
+}
+
+func (n *SimpleNet) handleWrite(conn *Connection, sock net.Conn, done chan struct{}) {
+	defer n.writers.Done()
 	defer func() {
 		err := recover()
 		if err != nil {
@@ -370,27 +474,100 @@ func (n *SimpleNet) handleWrite(conn *Connection) {
 				fmt.Sprintf("handleWrite panic: %s\n", err))
 		}
 	}()
+	w := &batchWriter{}
 	for {
 		select {
-		case msg, ok := <-conn.msgChan:
-			{
-				if !ok {
-					return
-				}
-				count, err := conn.conn.Write(msg)
-				if err = n.checkConnErr(count, err, conn); err != nil {
-					return
-				}
-				conn.upTime = time.Now()
-				n.logMsg(mylog.LevelInformational,
-					fmt.Sprintf("send data, count = %d, remoteAddr = %s\n",
-						count, conn.conn.RemoteAddr()))
+		case <-conn.queue.ready:
+			if n.writeQueue(conn, sock, w) != nil {
+				return
 			}
+		case <-conn.flush:
+			// Shutdown，发送完队列中剩余的数据后退出
+			n.writeQueue(conn, sock, w)
+			return
+		case <-done:
+			return
 		}
 	}
 }
 
+// writeQueue 发送队列中所有数据，每次把队列中的多个包合并成一次writev
+func (n *SimpleNet) writeQueue(conn *Connection, sock net.Conn, w *batchWriter) error {
+	maxBytes := conn.writeBatchSize()
+	for {
+		w.batch = conn.queue.popBatch(w.batch[:0], maxBytes, maxWriteBuffers)
+		if len(w.batch) == 0 {
+			return nil
+		}
+		err := n.writeBatch(conn, sock, w)
+		for i, msg := range w.batch {
+			msg.release()
+			w.batch[i] = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// batchWriter 写协程复用的合并写缓冲
+type batchWriter struct {
+	batch []*outMsg
+	bufs  [][]byte
+}
+
+func (n *SimpleNet) writeBatch(conn *Connection, sock net.Conn, w *batchWriter) error {
+	msgs := len(w.batch)
+	var count int64
+	var err error
+	if msgs == 1 {
+		var c int
+		c, err = sock.Write(w.batch[0].data)
+		count = (int64)(c)
+	} else {
+		w.bufs = w.bufs[:0]
+		for _, msg := range w.batch {
+			w.bufs = append(w.bufs, msg.data)
+		}
+		// 不支持writev的连接(如tls)会逐个Write
+		bufs := (net.Buffers)(w.bufs)
+		count, err = bufs.WriteTo(sock)
+	}
+	atomic.AddInt64(&conn.writes, 1)
+	conn.addBytesOut(count)
+	if err = n.checkConnErr((int)(count), err, conn, sock); err != nil {
+		return err
+	}
+	conn.touchWrite()
+	conn.addFramesOut(msgs)
+	n.logMsg(mylog.LevelInformational,
+		fmt.Sprintf("send data, count = %d, msgs = %d, remoteAddr = %s\n",
+			count, msgs, sock.RemoteAddr()))
+	return nil
+}
+
+// startIO 启动当前socket的读写协程，协程计数已由reserveIO预留
+func (n *SimpleNet) startIO(conn *Connection) {
+	conn.lock.Lock()
+	sock, done := conn.conn, conn.sockDone
+	conn.lock.Unlock()
+
+	go func() {
+		defer n.wg.Done()
+		n.handleRead(conn, sock)
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.handleWrite(conn, sock, done)
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.handleIdle(conn, sock, done)
+	}()
+}
+
 func (n *SimpleNet) listening(l *Listener) {
+	defer n.acceptWg.Done()
 	defer func() {
 		err := recover()
 		if err != nil {
@@ -403,49 +580,96 @@ func (n *SimpleNet) listening(l *Listener) {
 		if err != nil {
 			n.logMsg(mylog.LevelError,
 				fmt.Sprintf("accept failed, err = %s\n", err))
-			if l.status != StatusListenning {
+			if l.Status() != StatusListenning {
 				break
 			}
 			continue
 		}
-
-		conn := &Connection{
-			net:        l.net,
-			listen:     l,
-			id:         atomic.AddInt64(&n.nextid, 1),
-			status:     StatusConnected,
-			conn:       newconn,
-			msgChan:    make(chan []byte, 1024),
-			localAddr:  newconn.LocalAddr().String(),
-			remoteAddr: newconn.RemoteAddr().String(),
-			proto:      l.proto,
-			upTime:     time.Now(),
+		if l.opt.TLSConfig != nil {
+			// 握手较慢，不阻塞accept
+			n.acceptWg.Add(1)
+			go func() {
+				defer n.acceptWg.Done()
+				n.accept(l, newconn)
+			}()
+			continue
 		}
+		n.accept(l, newconn)
+	}
+}
 
-		if conn.proto != nil {
-			if !conn.proto.FilterAccept(conn) {
-				continue
-			}
+func (n *SimpleNet) accept(l *Listener, newconn net.Conn) {
+	if l.opt.TLSConfig != nil {
+		tlsconn, err := l.opt.serverHandshake(newconn)
+		if err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("tls handshake failed, remoteAddr = %s, err = %s\n",
+					newconn.RemoteAddr(), err))
+			newconn.Close()
+			return
 		}
+		newconn = tlsconn
+	}
 
-		n.syncAddClient(conn)
+	conn := &Connection{
+		net:        l.net,
+		listen:     l,
+		id:         atomic.AddInt64(&n.nextid, 1),
+		status:     StatusConnected,
+		conn:       newconn,
+		sockDone:   make(chan struct{}),
+		queue:      newSendQueue(l.opt),
+		flush:      make(chan struct{}),
+		closed:     make(chan struct{}),
+		network:    l.network,
+		localAddr:  newconn.LocalAddr().String(),
+		remoteAddr: newconn.RemoteAddr().String(),
+		proto:      l.proto,
+		opt:        l.opt,
+	}
+	conn.touch()
+	conn.idle.init(l.opt)
 
-		// emit EventNewConnection
-		event := &ConnEvent{
-			EventType: EventNewConnection,
-			Conn:      conn,
+	if conn.proto != nil {
+		if !conn.proto.FilterAccept(conn) {
+			newconn.Close()
+			return
 		}
-		n.events <- event
-
-		go n.handleRead(conn)
-		go n.handleWrite(conn)
+	}
+	if !n.register(conn) {
+		// 已经Shutdown或者Listener已经关闭
+		newconn.Close()
+		return
+	}
 
+	// emit EventNewConnection
+	event := &ConnEvent{
+		EventType: EventNewConnection,
+		Conn:      conn,
 	}
+	n.emit(event)
+
+	n.startIO(conn)
 }
 
 // Listen 监听网络 addr 为监听地址
 func (n *SimpleNet) Listen(addr string, proto IProto) (*Listener, error) {
-	listen, err := net.Listen("tcp", addr)
+	return n.ListenContext(context.Background(), addr, proto, nil)
+}
+
+// ListenWithHandler 监听网络，accept的连接事件通过h回调
+func (n *SimpleNet) ListenWithHandler(addr string, proto IProto, h Handler) (*Listener, error) {
+	return n.ListenContext(context.Background(), addr, proto, &Options{Handler: h})
+}
+
+// ListenContext 监听网络，ctx取消时关闭监听及其连接
+// addr可带网络前缀，如unix:///tmp/x.sock、udp://:9000，udp的每个对端地址视为一个连接
+func (n *SimpleNet) ListenContext(ctx context.Context, addr string, proto IProto, opt *Options) (*Listener, error) {
+	if n.isClosing() {
+		return nil, ErrShutdown
+	}
+	opt = copyOptions(opt)
+	network, addr, err := parseAddress(opt.Network, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -453,23 +677,76 @@ func (n *SimpleNet) Listen(addr string, proto IProto) (*Listener, error) {
 	l := &Listener{
 		net: n,
 
-		id:         atomic.AddInt64(&n.nextid, 1),
-		status:     StatusListenning,
-		listen:     listen,
-		lockClient: &sync.Mutex{},
+		id:      atomic.AddInt64(&n.nextid, 1),
+		status:  StatusListenning,
+		network: network,
+		closed:  make(chan struct{}),
+		conns:   make(map[int64]*Connection),
 
 		proto: proto,
+		opt:   opt,
+	}
+	if isPacketNetwork(network) {
+		if opt.TLSConfig != nil {
+			return nil, fmt.Errorf("tls not supported on %s", network)
+		}
+		l.packet, err = opt.listenConfig().ListenPacket(ctx, network, addr)
+		l.peers = make(map[string]*udpConn)
+	} else {
+		l.listen, err = opt.listenConfig().Listen(ctx, network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opt.Handler != nil {
+		l.handler.store(opt.Handler)
+	}
+	if !n.registerListen(l) {
+		if l.packet != nil {
+			l.packet.Close()
+		} else {
+			l.listen.Close()
+		}
+		return nil, ErrShutdown
 	}
-	n.syncAddListen(l)
 
-	go n.listening(l)
+	watchContext(ctx, l.closed, func() {
+		n.CloseListen(l)
+	})
+
+	if l.packet != nil {
+		go n.packetListening(l)
+	} else {
+		go n.listening(l)
+	}
 
 	return l, nil
 }
 
 // Connect 连接服务器器
 func (n *SimpleNet) Connect(addr string, proto IProto) (*Connection, error) {
-	newconn, err := net.Dial("tcp", addr)
+	return n.ConnectContext(context.Background(), addr, proto, nil)
+}
+
+// ConnectWithHandler 连接服务器，连接事件通过h回调
+func (n *SimpleNet) ConnectWithHandler(addr string, proto IProto, h Handler) (*Connection, error) {
+	return n.ConnectContext(context.Background(), addr, proto, &Options{Handler: h})
+}
+
+// ConnectContext 连接服务器，ctx可中断连接过程，连接成功后ctx取消时关闭连接
+func (n *SimpleNet) ConnectContext(ctx context.Context, addr string, proto IProto, opt *Options) (*Connection, error) {
+	if n.isClosing() {
+		return nil, ErrShutdown
+	}
+	opt = copyOptions(opt)
+	network, addr, err := parseAddress(opt.Network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if isPacketNetwork(network) && opt.TLSConfig != nil {
+		return nil, fmt.Errorf("tls not supported on %s", network)
+	}
+	newconn, err := opt.dial(ctx, network, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -479,16 +756,41 @@ func (n *SimpleNet) Connect(addr string, proto IProto) (*Connection, error) {
 		id:         atomic.AddInt64(&n.nextid, 1),
 		status:     StatusConnected,
 		conn:       newconn,
-		msgChan:    make(chan []byte, 1024),
+		sockDone:   make(chan struct{}),
+		queue:      newSendQueue(opt),
+		flush:      make(chan struct{}),
+		closed:     make(chan struct{}),
+		network:    network,
+		addr:       addr,
+		ctx:        ctx,
 		localAddr:  newconn.LocalAddr().String(),
 		remoteAddr: newconn.RemoteAddr().String(),
-		upTime:     time.Now(),
 		proto:      proto,
+		opt:        opt,
+	}
+	conn.touch()
+	conn.idle.init(opt)
+	if opt.Handler != nil {
+		conn.handler.store(opt.Handler)
+	}
+	if !n.register(conn) {
+		newconn.Close()
+		return nil, ErrShutdown
 	}
-	n.syncAddClient(conn)
 
-	go n.handleRead(conn)
-	go n.handleWrite(conn)
+	if opt.Handler != nil {
+		// 回调模式下主动连接也通知OnConnect
+		n.emit(&ConnEvent{
+			EventType: EventNewConnection,
+			Conn:      conn,
+		})
+	}
+
+	watchContext(ctx, conn.closed, func() {
+		n.CloseConn(conn)
+	})
+
+	n.startIO(conn)
 
 	return conn, nil
 }
@@ -515,47 +817,92 @@ func (n *SimpleNet) PollEvent(timeout int) (*ConnEvent, error) {
 }
 
 // SendData 向connection发送数据，如果connection不支持，data为[]byte
+// 发送队列满时按Options.Overflow处理，OverflowBlock一直等待到有空间或连接关闭
 func (n *SimpleNet) SendData(conn *Connection, data interface{}) error {
-	if conn.status != StatusConnected {
-		return fmt.Errorf("not connected connection")
+	return n.sendData(context.Background(), conn, data, true)
+}
+
+// checkSend 检查连接当前是否可以发送
+func (c *Connection) checkSend() error {
+	switch c.Status() {
+	case StatusConnected:
+	case StatusReconnecting:
+		if c.opt.Reconnect.RejectWhileReconnecting {
+			return ErrReconnecting
+		}
+	default:
+		return ErrNotConnected
 	}
-	if conn.proto == nil {
+	return nil
+}
+
+// serialize hint为上次序列化的长度，用来选择缓冲大小，避免append扩容
+func serialize(proto IProto, data interface{}, hint *int64) (*outMsg, error) {
+	if proto == nil {
 		msg, ok := (data).([]byte)
 		if !ok {
-			return fmt.Errorf("unexpect data type")
+			return nil, fmt.Errorf("unexpect data type")
 		}
-		conn.msgChan <- msg
-	} else {
-		msg, err := conn.proto.Serialize(data)
+		return newOutMsg(msg, false), nil
+	}
+	if p, ok := proto.(IBufferProto); ok {
+		size := (int)(atomic.LoadInt64(hint))
+		if size < defSerializeSize {
+			size = defSerializeSize
+		}
+		buf := GetBuffer(size)[:0]
+		msg, err := p.SerializeTo(buf, data)
 		if err != nil {
-			return err
+			PutBuffer(buf)
+			return nil, err
 		}
-		conn.msgChan <- msg
+		atomic.StoreInt64(hint, (int64)(len(msg)))
+		return newOutMsg(msg, true), nil
 	}
-	return nil
+	msg, err := proto.Serialize(data)
+	if err != nil {
+		return nil, err
+	}
+	return newOutMsg(msg, false), nil
 }
 
 // CloseConn 关闭连接
 func (n *SimpleNet) CloseConn(conn *Connection) error {
-	if conn.status == StatusConnected {
-		conn.status = StatusBroken
-		close(conn.msgChan)
-		conn.conn.Close()
+	n.breakConn(conn)
+	return nil
+}
 
-		n.syncDelClient(conn)
+// closeConnWithErr 关闭连接，读协程以err通知关闭事件
+func (n *SimpleNet) closeConnWithErr(conn *Connection, err error) {
+	conn.lock.Lock()
+	if conn.cause == nil {
+		conn.cause = err
 	}
-	return nil
+	conn.lock.Unlock()
+	n.breakConn(conn)
+}
+
+func (c *Connection) closeCause() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.cause
 }
 
 // CloseListen 关闭服务器
 func (n *SimpleNet) CloseListen(listen *Listener) error {
-	if listen.status == StatusListenning {
-		for _, v := range listen.conns {
-			n.CloseConn(v)
-		}
-		listen.status = StatusBroken
-		listen.listen.Close()
+	if !n.stopListen(listen) {
+		return nil
 	}
+	// stopListen之后不会再有新的连接登记到listen
+	listen.lock.Lock()
+	conns := make([]*Connection, 0, len(listen.conns))
+	for _, v := range listen.conns {
+		conns = append(conns, v)
+	}
+	listen.lock.Unlock()
 
+	for _, v := range conns {
+		n.CloseConn(v)
+	}
 	return nil
 }
@@ -0,0 +1,493 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+func TestConnectContextCancel(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := n.ConnectContext(ctx, "127.0.0.1:1", nil, nil)
+	if err == nil {
+		t.Fatalf("connect with canceled context should fail")
+	}
+}
+
+func TestListenContextCancel(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := newTestHandler()
+	listen, err := n.ListenContext(ctx, "127.0.0.1:0", nil, &Options{Handler: server})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	addr := listen.LocalAddress()
+
+	client := newTestHandler()
+	_, err = n.ConnectContext(context.Background(), addr, nil,
+		&Options{Handler: client, KeepAlive: time.Second, LocalAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	select {
+	case <-server.connect:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("server OnConnect timeout")
+	}
+
+	cancel()
+	select {
+	case <-client.close:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("client not closed after listen canceled")
+	}
+	select {
+	case <-listen.closed:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("listen not closed after canceled")
+	}
+}
+
+// lenProto 测试用协议，4字节大端长度头
+type lenProto struct{}
+
+func (p *lenProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+func (p *lenProto) HeadLen() uint32 {
+	return 4
+}
+func (p *lenProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, binary.BigEndian.Uint32(head), nil
+}
+func (p *lenProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return body, nil
+}
+func (p *lenProto) Serialize(data interface{}) ([]byte, error) {
+	body := data.([]byte)
+	buf := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint32(buf, (uint32)(len(body)))
+	return append(buf, body...), nil
+}
+
+func TestReadFrameFragmented(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0", &lenProto{},
+		&Options{Handler: server, MaxFrameSize: 1024})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	defer sock.Close()
+
+	frame, _ := (&lenProto{}).Serialize([]byte("fragmented frame"))
+	for _, b := range frame {
+		sock.Write([]byte{b})
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case b := <-server.data:
+		if string(b) != "fragmented frame" {
+			t.Fatalf("frame not match, got = %s", b)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive frame timeout")
+	}
+
+	// 超过MaxFrameSize直接断开
+	sock.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	select {
+	case err = <-server.close:
+		if !errors.Is(err, ErrFrameTooLarge) {
+			t.Fatalf("expect ErrFrameTooLarge, err = %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("large frame not closed")
+	}
+}
+
+func TestReadRawChunk(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0", nil,
+		&Options{Handler: server, ReadBufferSize: 4})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	defer sock.Close()
+
+	sock.Write([]byte("0123456789"))
+	got := ""
+	for len(got) < 10 {
+		select {
+		case b := <-server.data:
+			if len(b) > 4 {
+				t.Fatalf("chunk larger than read buffer, len = %d", len(b))
+			}
+			got += string(b)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("receive timeout, got = %s", got)
+		}
+	}
+	if got != "0123456789" {
+		t.Fatalf("data not match, got = %s", got)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		network, addr       string
+		expNetwork, expAddr string
+	}{
+		{"", "127.0.0.1:80", "tcp", "127.0.0.1:80"},
+		{"", "tcp://127.0.0.1:80", "tcp", "127.0.0.1:80"},
+		{"", "unix:///tmp/x.sock", "unix", "/tmp/x.sock"},
+		{"", "udp://:9000", "udp", ":9000"},
+		{"udp", ":9000", "udp", ":9000"},
+		{"tcp", "unix:///tmp/x.sock", "unix", "/tmp/x.sock"},
+	}
+	for _, c := range cases {
+		network, addr, err := parseAddress(c.network, c.addr)
+		if err != nil || network != c.expNetwork || addr != c.expAddr {
+			t.Fatalf("parse %s %s = %s %s %v", c.network, c.addr, network, addr, err)
+		}
+	}
+	if _, _, err := parseAddress("", "http://127.0.0.1"); err == nil {
+		t.Fatalf("unsupported network should fail")
+	}
+}
+
+func TestUnixTransport(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	path := filepath.Join(t.TempDir(), "simple.sock")
+	server := newTestHandler()
+	listen, err := n.ListenWithHandler("unix://"+path, &lenProto{}, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	if listen.Network() != "unix" {
+		t.Fatalf("network = %s, expect unix", listen.Network())
+	}
+	conn, err := n.ConnectWithHandler("unix://"+path, &lenProto{}, newTestHandler())
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	if err = n.SendData(conn, []byte("over unix")); err != nil {
+		t.Fatalf("send failed, err = %s", err)
+	}
+	select {
+	case b := <-server.data:
+		if string(b) != "over unix" {
+			t.Fatalf("data not match, got = %s", b)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive timeout")
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenWithHandler("udp://127.0.0.1:0", &lenProto{}, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	clients := make(map[string]*Connection)
+	for i := 0; i < 2; i++ {
+		client := newTestHandler()
+		conn, err := n.ConnectWithHandler("udp://"+listen.LocalAddress(), &lenProto{}, client)
+		if err != nil {
+			t.Fatalf("connect failed, err = %s", err)
+		}
+		clients[conn.LocalAddress()] = conn
+		for j := 0; j < 2; j++ {
+			if err = n.SendData(conn, []byte("datagram")); err != nil {
+				t.Fatalf("send failed, err = %s", err)
+			}
+		}
+
+		var peer *Connection
+		select {
+		case peer = <-server.connect:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("udp peer not accepted")
+		}
+		if _, ok := clients[peer.RemoteAddress()]; !ok {
+			t.Fatalf("udp peer %s not match client", peer.RemoteAddress())
+		}
+		for j := 0; j < 2; j++ {
+			select {
+			case b := <-server.data:
+				if string(b) != "datagram" {
+					t.Fatalf("data not match, got = %s", b)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatalf("receive timeout")
+			}
+		}
+
+		// 服务端通过伪连接回包
+		if err = n.SendData(peer, []byte("reply")); err != nil {
+			t.Fatalf("reply failed, err = %s", err)
+		}
+		select {
+		case b := <-client.data:
+			if string(b) != "reply" {
+				t.Fatalf("reply not match, got = %s", b)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("receive reply timeout")
+		}
+	}
+}
+
+func TestUDPPeerLimit(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenContext(context.Background(), "udp://127.0.0.1:0", &lenProto{},
+		&Options{Handler: server, PeerIdle: time.Millisecond * 200, MaxPeers: 1})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	peers := func() int {
+		listen.lock.Lock()
+		defer listen.lock.Unlock()
+		return len(listen.peers)
+	}
+	send := func() *Connection {
+		conn, err := n.ConnectContext(context.Background(), "udp://"+listen.LocalAddress(), &lenProto{},
+			&Options{Handler: newTestHandler()})
+		if err != nil {
+			t.Fatalf("connect failed, err = %s", err)
+		}
+		if err = n.SendData(conn, []byte("datagram")); err != nil {
+			t.Fatalf("send failed, err = %s", err)
+		}
+		return conn
+	}
+
+	send()
+	select {
+	case <-server.connect:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("udp peer not accepted")
+	}
+	// 超过MaxPeers的对端被丢弃
+	send()
+	time.Sleep(time.Millisecond * 50)
+	if got := peers(); got != 1 {
+		t.Fatalf("peers not limited, got = %d", got)
+	}
+
+	// 空闲的对端被关闭并移除
+	select {
+	case err = <-server.close:
+		if err != ErrIdleTimeout {
+			t.Fatalf("close err = %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("idle peer not closed")
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for peers() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("idle peer not removed, peers = %d", peers())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// 移除后新的对端可以接入
+	send()
+	select {
+	case <-server.connect:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("udp peer not accepted after idle close")
+	}
+}
+
+func TestWriteBatchOrder(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	server.data = make(chan []byte, 1024)
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), &lenProto{},
+		&Options{Handler: &NopHandler{}, WriteBatchSize: 256})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	const count = 1000
+	for i := 0; i < count; i++ {
+		if err = n.SendData(conn, []byte(fmt.Sprintf("frame %d", i))); err != nil {
+			t.Fatalf("send failed, err = %s", err)
+		}
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case b := <-server.data:
+			if expect := fmt.Sprintf("frame %d", i); string(b) != expect {
+				t.Fatalf("frame not match, got = %s, expect = %s", b, expect)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("receive frame %d timeout", i)
+		}
+	}
+}
+
+var callerSinkSeq int64
+
+// callerSink 记录格式化后的日志，注册表是全局的，每次用不同的名字注册
+type callerSink struct {
+	name  string
+	lock  sync.Mutex
+	lines []string
+}
+
+func (c *callerSink) Name() string           { return c.name }
+func (c *callerSink) Open(conf string) error { return nil }
+func (c *callerSink) Write(msg *mylog.Message) (int, error) {
+	line := string(mylog.TextFormatter{}.Format(nil, msg))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lines = append(c.lines, line)
+	return len(line), nil
+}
+func (c *callerSink) Close() error { return nil }
+func (c *callerSink) Sync() error  { return nil }
+
+func TestLogMsgCaller(t *testing.T) {
+	sink := &callerSink{name: fmt.Sprintf("net_caller_%d", atomic.AddInt64(&callerSinkSeq, 1))}
+	if err := mylog.Register(sink); err != nil {
+		t.Fatalf("register failed, err = %s", err)
+	}
+	log := mylog.New("net")
+	if _, err := log.AddSink(sink.name, "{}"); err != nil {
+		t.Fatalf("add sink failed, err = %s", err)
+	}
+	log.SetCaller(mylog.CallerFile)
+	log.StartSync()
+
+	n := NewSimpleNet(log)
+	_, _, line, _ := runtime.Caller(0)
+	n.logMsg(mylog.LevelError, "conn err = EOF\n")
+	SimpleNetDestroy(n)
+	log.Stop()
+
+	want := fmt.Sprintf(" connection_test.go:%d conn err = EOF\n", line+1)
+	if len(sink.lines) == 0 || !strings.HasSuffix(sink.lines[0], want) {
+		t.Fatalf("caller not match, got = %q, want suffix = %q", sink.lines, want)
+	}
+}
+
+// quiet 没有log时网络日志直接输出到stdout，压测时丢弃
+func quiet(b *testing.B) {
+	stdout := os.Stdout
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatalf("open %s failed, err = %s", os.DevNull, err)
+	}
+	os.Stdout = devnull
+	b.Cleanup(func() {
+		os.Stdout = stdout
+		devnull.Close()
+	})
+}
+
+// countHandler 收到expect个包后关闭done
+type countHandler struct {
+	NopHandler
+	count  int64
+	expect int64
+	done   chan struct{}
+}
+
+func (h *countHandler) OnData(conn *Connection, data interface{}) {
+	if atomic.AddInt64(&h.count, 1) == h.expect {
+		close(h.done)
+	}
+}
+
+func benchmarkWrite(b *testing.B, batch int) {
+	quiet(b)
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := &countHandler{expect: (int64)(b.N), done: make(chan struct{})}
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, server)
+	if err != nil {
+		b.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), &lenProto{},
+		&Options{Handler: &NopHandler{}, WriteBatchSize: batch})
+	if err != nil {
+		b.Fatalf("connect failed, err = %s", err)
+	}
+
+	body := make([]byte, 60)
+	b.SetBytes((int64)(len(body) + 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = n.SendData(conn, body); err != nil {
+			b.Fatalf("send failed, err = %s", err)
+		}
+	}
+	select {
+	case <-server.done:
+	case <-time.After(time.Second * 30):
+		b.Fatalf("receive timeout, count = %d", atomic.LoadInt64(&server.count))
+	}
+	b.StopTimer()
+	b.ReportMetric((float64)(atomic.LoadInt64(&conn.writes))/(float64)(b.N), "writes/op")
+}
+
+// BenchmarkWrite 对比逐包Write与合并writev的吞吐及系统调用次数
+func BenchmarkWrite(b *testing.B) {
+	b.Run("single", func(b *testing.B) {
+		benchmarkWrite(b, -1)
+	})
+	b.Run("batched", func(b *testing.B) {
+		benchmarkWrite(b, 0)
+	})
+}
@@ -0,0 +1,26 @@
+package net
+
+import "errors"
+
+var (
+	// ErrShutdown SimpleNet已经关闭
+	ErrShutdown = errors.New("simple net shutdown")
+	// ErrNotConnected 连接已经关闭
+	ErrNotConnected = errors.New("not connected connection")
+	// ErrQueueFull 发送队列已满
+	ErrQueueFull = errors.New("send queue full")
+	// ErrCallUnsupported proto没有实现ICorrelator
+	ErrCallUnsupported = errors.New("proto not support call")
+	// ErrReconnecting 连接正在重连，且策略为拒绝发送
+	ErrReconnecting = errors.New("connection reconnecting")
+	// ErrFrameTooLarge 包体长度超过MaxFrameSize
+	ErrFrameTooLarge = errors.New("frame too large")
+	// ErrBadHeader 帧头不合法，之后的数据无法对齐
+	ErrBadHeader = errors.New("bad frame header")
+	// ErrUnknownCommand 包头中的命令字没有对应的消息
+	ErrUnknownCommand = errors.New("unknown command")
+	// ErrIdleTimeout 连接空闲超时被关闭
+	ErrIdleTimeout = errors.New("idle timeout")
+	// ErrHeartbeatTimeout 心跳超时被关闭
+	ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+)
@@ -0,0 +1,305 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Framer 分帧方式，把字节流切分成包体
+type Framer interface {
+	// ReadFrame 从r读取一帧，返回的包体来自缓冲池。
+	// 帧头不合法返回ErrBadHeader，包体超过maxFrame返回ErrFrameTooLarge，此时流已无法对齐
+	ReadFrame(r *bufio.Reader, maxFrame uint32) ([]byte, error)
+	// AppendFrame 追加一帧到buf，包体由encode追加到传入的buf之后
+	AppendFrame(buf []byte, encode func(buf []byte) ([]byte, error)) ([]byte, error)
+}
+
+// LengthFramer 定长的长度字段 + 包体
+type LengthFramer struct {
+	// Size 长度字段字节数，1、2、4或8
+	Size int
+	// Order 长度字段字节序，nil为大端
+	Order binary.ByteOrder
+	// Inclusive 长度是否包含长度字段本身
+	Inclusive bool
+}
+
+func (f *LengthFramer) order() binary.ByteOrder {
+	if f.Order == nil {
+		return binary.BigEndian
+	}
+	return f.Order
+}
+
+func (f *LengthFramer) check() error {
+	switch f.Size {
+	case 1, 2, 4, 8:
+		return nil
+	}
+	return fmt.Errorf("invalid length field size %d", f.Size)
+}
+
+// ReadFrame Framer
+func (f *LengthFramer) ReadFrame(r *bufio.Reader, maxFrame uint32) ([]byte, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	var head [8]byte
+	if _, err := io.ReadFull(r, head[:f.Size]); err != nil {
+		return nil, err
+	}
+	var length uint64
+	switch f.Size {
+	case 1:
+		length = (uint64)(head[0])
+	case 2:
+		length = (uint64)(f.order().Uint16(head[:]))
+	case 4:
+		length = (uint64)(f.order().Uint32(head[:]))
+	case 8:
+		length = f.order().Uint64(head[:])
+	}
+	if f.Inclusive {
+		if length < (uint64)(f.Size) {
+			return nil, fmt.Errorf("%w: length %d less than length field", ErrBadHeader, length)
+		}
+		length -= (uint64)(f.Size)
+	}
+	return readBody(r, length, maxFrame)
+}
+
+// AppendFrame Framer
+func (f *LengthFramer) AppendFrame(buf []byte, encode func(buf []byte) ([]byte, error)) ([]byte, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	var zero [8]byte
+	start := len(buf)
+	buf, err := encode(append(buf, zero[:f.Size]...))
+	if err != nil {
+		return nil, err
+	}
+	length := (uint64)(len(buf) - start)
+	if !f.Inclusive {
+		length -= (uint64)(f.Size)
+	}
+	if f.Size < 8 && length >= 1<<(8*f.Size) {
+		return nil, fmt.Errorf("frame length %d overflows %d bytes length field", length, f.Size)
+	}
+	head := buf[start:]
+	switch f.Size {
+	case 1:
+		head[0] = (byte)(length)
+	case 2:
+		f.order().PutUint16(head, (uint16)(length))
+	case 4:
+		f.order().PutUint32(head, (uint32)(length))
+	case 8:
+		f.order().PutUint64(head, length)
+	}
+	return buf, nil
+}
+
+// VarintFramer 无符号varint长度 + 包体，与protobuf的delimited格式相同
+type VarintFramer struct{}
+
+// ReadFrame Framer
+func (f *VarintFramer) ReadFrame(r *bufio.Reader, maxFrame uint32) ([]byte, error) {
+	var length uint64
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if i == binary.MaxVarintLen64-1 && b > 1 {
+			return nil, fmt.Errorf("%w: varint overflows 64 bits", ErrBadHeader)
+		}
+		length |= (uint64)(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			break
+		}
+	}
+	return readBody(r, length, maxFrame)
+}
+
+// AppendFrame Framer
+func (f *VarintFramer) AppendFrame(buf []byte, encode func(buf []byte) ([]byte, error)) ([]byte, error) {
+	// 先预留最大的长度字段，编码后再把包体移到实际长度字段之后
+	var zero [binary.MaxVarintLen64]byte
+	start := len(buf)
+	buf, err := encode(append(buf, zero[:]...))
+	if err != nil {
+		return nil, err
+	}
+	body := start + binary.MaxVarintLen64
+	length := len(buf) - body
+	n := binary.PutUvarint(buf[start:], (uint64)(length))
+	copy(buf[start+n:], buf[body:])
+	return buf[:start+n+length], nil
+}
+
+// DelimFramer 以分隔符结尾的帧，包体不包含分隔符，如按行分帧的"\n"、"\r\n"
+type DelimFramer struct {
+	Delim []byte
+}
+
+// ReadFrame Framer，超过maxFrame仍未找到分隔符返回ErrFrameTooLarge
+func (f *DelimFramer) ReadFrame(r *bufio.Reader, maxFrame uint32) ([]byte, error) {
+	if len(f.Delim) == 0 {
+		return nil, fmt.Errorf("empty delimiter")
+	}
+	last := f.Delim[len(f.Delim)-1]
+	limit := (uint64)(maxFrame) + (uint64)(len(f.Delim))
+
+	var frame []byte
+	for {
+		line, err := r.ReadSlice(last)
+		if (uint64)(len(frame)+len(line)) > limit {
+			PutBuffer(frame)
+			return nil, fmt.Errorf("%w: no delimiter in %d bytes", ErrFrameTooLarge, maxFrame)
+		}
+		frame = appendPooled(frame, line)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			PutBuffer(frame)
+			return nil, err
+		}
+		if bytes.HasSuffix(frame, f.Delim) {
+			return frame[:len(frame)-len(f.Delim)], nil
+		}
+	}
+}
+
+// AppendFrame Framer，包体中含有分隔符时返回错误
+func (f *DelimFramer) AppendFrame(buf []byte, encode func(buf []byte) ([]byte, error)) ([]byte, error) {
+	if len(f.Delim) == 0 {
+		return nil, fmt.Errorf("empty delimiter")
+	}
+	start := len(buf)
+	buf, err := encode(buf)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(buf[start:], f.Delim) {
+		return nil, fmt.Errorf("body contains delimiter %q", f.Delim)
+	}
+	return append(buf, f.Delim...), nil
+}
+
+// FixedFramer 定长记录，没有帧头
+type FixedFramer struct {
+	Size uint32
+}
+
+// ReadFrame Framer
+func (f *FixedFramer) ReadFrame(r *bufio.Reader, maxFrame uint32) ([]byte, error) {
+	if f.Size == 0 {
+		return nil, fmt.Errorf("invalid record size 0")
+	}
+	return readBody(r, (uint64)(f.Size), maxFrame)
+}
+
+// AppendFrame Framer，包体长度必须等于Size
+func (f *FixedFramer) AppendFrame(buf []byte, encode func(buf []byte) ([]byte, error)) ([]byte, error) {
+	start := len(buf)
+	buf, err := encode(buf)
+	if err != nil {
+		return nil, err
+	}
+	if size := len(buf) - start; size != (int)(f.Size) {
+		return nil, fmt.Errorf("record size %d, expect %d", size, f.Size)
+	}
+	return buf, nil
+}
+
+func readBody(r *bufio.Reader, length uint64, maxFrame uint32) ([]byte, error) {
+	if length > (uint64)(maxFrame) {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, length, maxFrame)
+	}
+	body := GetBuffer((int)(length))
+	if _, err := io.ReadFull(r, body); err != nil {
+		PutBuffer(body)
+		return nil, err
+	}
+	return body, nil
+}
+
+// appendPooled 追加到来自缓冲池的b，容量不够时换更大的缓冲并回收b
+func appendPooled(b []byte, data []byte) []byte {
+	if cap(b)-len(b) < len(data) {
+		nb := GetBuffer(2*cap(b) + len(data))[:len(b)]
+		copy(nb, b)
+		PutBuffer(b)
+		b = nb
+	}
+	return append(b, data...)
+}
+
+// FrameProto 由Framer分帧、Codec编解码包体的IProto。
+// SimpleNet直接调用Framer读取，HeadLen、BodyLen不使用
+type FrameProto struct {
+	Framer Framer
+	// Codec nil时为RawCodec
+	Codec Codec
+}
+
+// NewFrameProto 创建
+func NewFrameProto(framer Framer, codec Codec) *FrameProto {
+	return &FrameProto{
+		Framer: framer,
+		Codec:  codec,
+	}
+}
+
+func (p *FrameProto) codec() Codec {
+	if p.Codec == nil {
+		return RawCodec{}
+	}
+	return p.Codec
+}
+
+// FilterAccept IProto
+func (p *FrameProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+// HeadLen IProto，不使用
+func (p *FrameProto) HeadLen() uint32 {
+	return 0
+}
+
+// BodyLen IProto，不使用
+func (p *FrameProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("FrameProto is read by Framer")
+}
+
+// Parse IProto，解码包体
+func (p *FrameProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return p.codec().Decode(body)
+}
+
+// Serialize IProto
+func (p *FrameProto) Serialize(data interface{}) ([]byte, error) {
+	return p.SerializeTo(nil, data)
+}
+
+// SerializeTo IBufferProto
+func (p *FrameProto) SerializeTo(buf []byte, data interface{}) ([]byte, error) {
+	codec := p.codec()
+	return p.Framer.AppendFrame(buf, func(buf []byte) ([]byte, error) {
+		return codec.Encode(buf, data)
+	})
+}
+
+// RetainBody IBufferProto，由Codec决定
+func (p *FrameProto) RetainBody() bool {
+	return p.codec().RetainBody()
+}
@@ -0,0 +1,197 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestFramerRoundTrip(t *testing.T) {
+	long := strings.Repeat("long body ", 20)
+	tests := []struct {
+		name   string
+		framer Framer
+		bodies []string
+	}{
+		{"len1", &LengthFramer{Size: 1}, []string{"hello", "", long}},
+		{"len2le", &LengthFramer{Size: 2, Order: binary.LittleEndian}, []string{"hello", "", long}},
+		{"len4inclusive", &LengthFramer{Size: 4, Inclusive: true}, []string{"hello", "", long}},
+		{"len8", &LengthFramer{Size: 8}, []string{"hello", "", long}},
+		{"varint", &VarintFramer{}, []string{"hello", "", long}},
+		{"line", &DelimFramer{Delim: []byte("\n")}, []string{"hello", "", long}},
+		{"crlf", &DelimFramer{Delim: []byte("\r\n")}, []string{"hel\rlo", "", long}},
+		{"fixed", &FixedFramer{Size: 5}, []string{"hello", "world"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewFrameProto(tt.framer, nil)
+			var stream []byte
+			for _, body := range tt.bodies {
+				var err error
+				stream, err = p.SerializeTo(stream, body)
+				if err != nil {
+					t.Fatalf("serialize failed, err = %s", err)
+				}
+			}
+			// 每次只读一个字节，覆盖帧跨多次读取的情况
+			reader := bufio.NewReaderSize(iotest.OneByteReader(bytes.NewReader(stream)), 16)
+			for _, expect := range tt.bodies {
+				body, err := tt.framer.ReadFrame(reader, 1024)
+				if err != nil {
+					t.Fatalf("read frame failed, err = %s", err)
+				}
+				if string(body) != expect {
+					t.Fatalf("frame not match, got = %q, expect = %q", body, expect)
+				}
+			}
+			if _, err := tt.framer.ReadFrame(reader, 1024); err != io.EOF {
+				t.Fatalf("expect EOF, err = %v", err)
+			}
+		})
+	}
+}
+
+func TestFramerError(t *testing.T) {
+	read := func(f Framer, stream []byte) error {
+		_, err := f.ReadFrame(bufio.NewReader(bytes.NewReader(stream)), 8)
+		return err
+	}
+	if err := read(&LengthFramer{Size: 2}, []byte{0, 9}); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expect ErrFrameTooLarge, err = %v", err)
+	}
+	if err := read(&LengthFramer{Size: 2, Inclusive: true}, []byte{0, 1}); !errors.Is(err, ErrBadHeader) {
+		t.Fatalf("expect ErrBadHeader, err = %v", err)
+	}
+	if err := read(&VarintFramer{}, bytes.Repeat([]byte{0xff}, 10)); !errors.Is(err, ErrBadHeader) {
+		t.Fatalf("expect ErrBadHeader, err = %v", err)
+	}
+	if err := read(&VarintFramer{}, []byte{0x80}); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expect ErrUnexpectedEOF, err = %v", err)
+	}
+	if err := read(&DelimFramer{Delim: []byte("\n")}, []byte("0123456789\n")); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expect ErrFrameTooLarge, err = %v", err)
+	}
+	if err := read(&LengthFramer{Size: 3}, []byte{0, 0, 0}); err == nil {
+		t.Fatalf("invalid length field size accepted")
+	}
+
+	if _, err := NewFrameProto(&LengthFramer{Size: 1}, nil).Serialize(make([]byte, 256)); err == nil {
+		t.Fatalf("length overflow accepted")
+	}
+	if _, err := NewFrameProto(&DelimFramer{Delim: []byte("\n")}, nil).Serialize("a\nb"); err == nil {
+		t.Fatalf("body with delimiter accepted")
+	}
+	if _, err := NewFrameProto(&FixedFramer{Size: 4}, nil).Serialize("abc"); err == nil {
+		t.Fatalf("record size mismatch accepted")
+	}
+}
+
+type jsonMsg struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type jsonHandler struct {
+	NopHandler
+	data  chan *jsonMsg
+	proto chan error
+}
+
+func (h *jsonHandler) OnData(conn *Connection, data interface{}) {
+	h.data <- data.(*jsonMsg)
+}
+func (h *jsonHandler) OnProtoError(conn *Connection, err error) {
+	h.proto <- err
+}
+
+func TestFrameProto(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	newProto := func() *FrameProto {
+		return NewFrameProto(&DelimFramer{Delim: []byte("\r\n")},
+			&JSONCodec{New: func() interface{} { return &jsonMsg{} }})
+	}
+	server := &jsonHandler{data: make(chan *jsonMsg, 16), proto: make(chan error, 16)}
+	listen, err := n.ListenWithHandler("127.0.0.1:0", newProto(), server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), newProto(), &NopHandler{})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	if err = n.SendData(conn, &jsonMsg{ID: 1, Name: "first"}); err != nil {
+		t.Fatalf("send failed, err = %s", err)
+	}
+	select {
+	case m := <-server.data:
+		if m.ID != 1 || m.Name != "first" {
+			t.Fatalf("message not match, got = %+v", m)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive timeout")
+	}
+
+	// 包体解码失败不影响分帧，之后的消息正常接收
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	defer sock.Close()
+	sock.Write([]byte("not json\r\n{\"id\":2,\"name\":\"second\"}\r\n"))
+	select {
+	case err = <-server.proto:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("proto error timeout")
+	}
+	select {
+	case m := <-server.data:
+		if m.ID != 2 || m.Name != "second" {
+			t.Fatalf("message not match, got = %+v", m)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive timeout")
+	}
+}
+
+func TestFrameProtoTooLarge(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0",
+		NewFrameProto(&VarintFramer{}, nil), &Options{Handler: server, MaxFrameSize: 16})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	defer sock.Close()
+
+	frame, _ := NewFrameProto(&VarintFramer{}, nil).Serialize("varint")
+	sock.Write(frame)
+	if got := recvString(t, server.data, 6); got != "varint" {
+		t.Fatalf("frame not match, got = %s", got)
+	}
+	frame, _ = NewFrameProto(&VarintFramer{}, nil).Serialize(strings.Repeat("x", 17))
+	sock.Write(frame)
+	select {
+	case err = <-server.close:
+		if !errors.Is(err, ErrFrameTooLarge) {
+			t.Fatalf("expect ErrFrameTooLarge, err = %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("large frame not closed")
+	}
+}
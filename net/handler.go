@@ -0,0 +1,240 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const (
+	defWorkerQueueSize = 1024
+)
+
+// Handler 事件回调，设置了Handler的连接，事件不再进入PollEvent队列
+type Handler interface {
+	OnConnect(conn *Connection)
+	OnData(conn *Connection, data interface{})
+	OnClose(conn *Connection, err error)
+	OnError(conn *Connection, err error)
+	OnProtoError(conn *Connection, err error)
+}
+
+// NopHandler 空实现，嵌入后只需实现关心的回调
+type NopHandler struct{}
+
+func (h NopHandler) OnConnect(conn *Connection)                {}
+func (h NopHandler) OnData(conn *Connection, data interface{}) {}
+func (h NopHandler) OnClose(conn *Connection, err error)       {}
+func (h NopHandler) OnError(conn *Connection, err error)       {}
+func (h NopHandler) OnProtoError(conn *Connection, err error)  {}
+
+type handlerHolder struct {
+	h Handler
+}
+
+type handlerValue struct {
+	v atomic.Value
+}
+
+func (v *handlerValue) load() Handler {
+	if hold, ok := v.v.Load().(handlerHolder); ok {
+		return hold.h
+	}
+	return nil
+}
+func (v *handlerValue) store(h Handler) {
+	v.v.Store(handlerHolder{h: h})
+}
+
+type handlerJob struct {
+	h   Handler
+	evt *ConnEvent
+}
+
+// workerPool 回调工作池，同一个连接的事件总是落在同一个worker上，保证顺序
+type workerPool struct {
+	net    *SimpleNet
+	queues []chan *handlerJob
+	wg     sync.WaitGroup
+	ids    sync.Map // worker的协程id，用于判断stop是否在回调中调用
+}
+
+func newWorkerPool(n *SimpleNet, workers int, queueSize int) *workerPool {
+	p := &workerPool{
+		net:    n,
+		queues: make([]chan *handlerJob, workers),
+	}
+	for i := 0; i < workers; i++ {
+		p.queues[i] = make(chan *handlerJob, queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *workerPool) work(queue chan *handlerJob) {
+	defer p.wg.Done()
+	p.ids.Store(mylog.GoID(), struct{}{})
+	for job := range queue {
+		p.call(job)
+	}
+}
+
+func (p *workerPool) call(job *handlerJob) {
+	defer func() {
+		err := recover()
+		if err != nil {
+			p.net.logMsg(mylog.LevelError,
+				fmt.Sprintf("handler panic: %s\n", err))
+		}
+	}()
+	evt := job.evt
+	err, _ := evt.Data.(error)
+	switch evt.EventType {
+	case EventNewConnection:
+		job.h.OnConnect(evt.Conn)
+	case EventNewConnectionData:
+		job.h.OnData(evt.Conn, evt.Data)
+		if evt.Conn.opt.ReleaseData {
+			evt.Release()
+		}
+	case EventConnectionClosed:
+		job.h.OnClose(evt.Conn, err)
+	case EventConnectionError:
+		job.h.OnError(evt.Conn, err)
+	case EventProtoError:
+		job.h.OnProtoError(evt.Conn, err)
+	case EventReconnecting:
+		if h, ok := job.h.(ReconnectHandler); ok {
+			h.OnReconnecting(evt.Conn, err)
+		}
+	case EventReconnected:
+		if h, ok := job.h.(ReconnectHandler); ok {
+			h.OnReconnected(evt.Conn)
+		}
+	case EventIdle:
+		if h, ok := job.h.(IdleHandler); ok {
+			h.OnIdle(evt.Conn, evt.Data.(int))
+		}
+	}
+}
+
+func (p *workerPool) dispatch(h Handler, evt *ConnEvent, done <-chan struct{}) {
+	index := 0
+	if evt.Conn != nil {
+		index = (int)(evt.Conn.id % (int64)(len(p.queues)))
+	}
+	job := &handlerJob{h: h, evt: evt}
+	// 先尝试不阻塞投递，done已关闭时select随机选择，队列有空位也可能丢弃事件
+	select {
+	case p.queues[index] <- job:
+		return
+	default:
+	}
+	select {
+	case p.queues[index] <- job:
+	case <-done:
+	case <-p.net.done:
+	}
+}
+
+// stop 关闭队列并等待worker退出。在回调中调用时不能等待自身，
+// 只关闭队列，各worker执行完剩余的回调后退出
+func (p *workerPool) stop() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	if _, ok := p.ids.Load(mylog.GoID()); ok {
+		return
+	}
+	p.wg.Wait()
+}
+
+// SetWorkerPool 设置回调工作池大小，须在第一个回调事件产生之前调用
+func (n *SimpleNet) SetWorkerPool(workers int, queueSize int) error {
+	if workers <= 0 || queueSize < 0 {
+		return fmt.Errorf("invalid worker pool size")
+	}
+	n.lockPool.Lock()
+	defer n.lockPool.Unlock()
+
+	if n.pool != nil {
+		return fmt.Errorf("worker pool already started")
+	}
+	n.poolWorkers = workers
+	n.poolQueue = queueSize
+
+	return nil
+}
+
+func (n *SimpleNet) workers() *workerPool {
+	n.lockPool.Lock()
+	defer n.lockPool.Unlock()
+
+	if n.pool == nil && !n.poolClosed {
+		workers := n.poolWorkers
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		queueSize := n.poolQueue
+		if queueSize <= 0 {
+			queueSize = defWorkerQueueSize
+		}
+		n.pool = newWorkerPool(n, workers, queueSize)
+	}
+	return n.pool
+}
+
+func (n *SimpleNet) stopWorkers() {
+	n.lockPool.Lock()
+	defer n.lockPool.Unlock()
+
+	if n.pool != nil {
+		n.pool.stop()
+		n.pool = nil
+	}
+	n.poolClosed = true
+}
+
+// handler 连接的回调，优先使用连接自身的，其次是所属Listener的
+func (n *SimpleNet) handler(conn *Connection) Handler {
+	if conn == nil {
+		return nil
+	}
+	if h := conn.handler.load(); h != nil {
+		return h
+	}
+	if conn.listen != nil {
+		return conn.listen.handler.load()
+	}
+	return nil
+}
+
+// emit 派发事件，有回调的走工作池，否则进入PollEvent队列
+func (n *SimpleNet) emit(event *ConnEvent) {
+	n.emitContext(context.Background(), event)
+}
+
+// emitContext 派发事件，队列未满时总是投递，队列满时最多阻塞到ctx结束或Shutdown不再等待派发
+func (n *SimpleNet) emitContext(ctx context.Context, event *ConnEvent) {
+	if h := n.handler(event.Conn); h != nil {
+		if p := n.workers(); p != nil {
+			p.dispatch(h, event, ctx.Done())
+		}
+		return
+	}
+	select {
+	case n.events <- event:
+		return
+	default:
+	}
+	select {
+	case n.events <- event:
+	case <-ctx.Done():
+	case <-n.done:
+	}
+}
@@ -0,0 +1,105 @@
+package net
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type testHandler struct {
+	NopHandler
+	connect chan *Connection
+	data    chan []byte
+	close   chan error
+}
+
+func newTestHandler() *testHandler {
+	return &testHandler{
+		connect: make(chan *Connection, 16),
+		data:    make(chan []byte, 1024),
+		close:   make(chan error, 16),
+	}
+}
+
+func (h *testHandler) OnConnect(conn *Connection) {
+	h.connect <- conn
+}
+func (h *testHandler) OnData(conn *Connection, data interface{}) {
+	h.data <- data.([]byte)
+}
+func (h *testHandler) OnClose(conn *Connection, err error) {
+	h.close <- err
+}
+func (h *testHandler) OnError(conn *Connection, err error) {
+	h.close <- err
+}
+
+// recvString 收集size个字节，raw模式下数据可能被拆成多个事件
+func recvString(t *testing.T, data chan []byte, size int) string {
+	got := ""
+	for len(got) < size {
+		select {
+		case b := <-data:
+			got += string(b)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("receive timeout, got = %s", got)
+		}
+	}
+	return got
+}
+
+func TestHandlerEcho(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	var echo sync.WaitGroup
+	server := newTestHandler()
+	listen, err := n.ListenWithHandler("127.0.0.1:0", nil, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	client := newTestHandler()
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), nil, client)
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	select {
+	case <-client.connect:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("client OnConnect timeout")
+	}
+	var peer *Connection
+	select {
+	case peer = <-server.connect:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("server OnConnect timeout")
+	}
+
+	echo.Add(1)
+	go func() {
+		defer echo.Done()
+		for b := range server.data {
+			if err := n.SendData(peer, b); err != nil {
+				return
+			}
+		}
+	}()
+
+	msg := "hello handler"
+	if err = n.SendData(conn, []byte(msg)); err != nil {
+		t.Fatalf("send failed, err = %s", err)
+	}
+	if got := recvString(t, client.data, len(msg)); got != msg {
+		t.Fatalf("echo not match, got = %s", got)
+	}
+
+	n.CloseConn(peer)
+	select {
+	case <-client.close:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("client OnClose timeout")
+	}
+	close(server.data)
+	echo.Wait()
+}
@@ -0,0 +1,224 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// EventIdle 事件的Data
+const (
+	IdleRead = iota + 1
+	IdleWrite
+	IdleAll
+)
+
+const (
+	minIdleTick = time.Millisecond * 10
+)
+
+// IHeartbeat proto可选实现，由框架自动发送、应答心跳，心跳包不再作为数据事件投递
+type IHeartbeat interface {
+	// Heartbeat 生成一个心跳请求
+	Heartbeat(conn *Connection) (interface{}, error)
+	// HeartbeatReply data为心跳包时ok返回true，reply不为nil时回送给对端
+	HeartbeatReply(conn *Connection, data interface{}) (reply interface{}, ok bool)
+}
+
+// IdleHandler Handler可选实现，接收空闲事件
+type IdleHandler interface {
+	OnIdle(conn *Connection, idle int)
+}
+
+// idleConfig 空闲及心跳参数，单位纳秒，0为不检查
+type idleConfig struct {
+	read      int64
+	write     int64
+	all       int64
+	close     int32
+	hbSend    int64
+	hbTimeout int64
+
+	changed chan struct{}
+}
+
+func (c *idleConfig) init(opt *Options) {
+	c.changed = make(chan struct{}, 1)
+	c.set(opt.ReadIdle, opt.WriteIdle, opt.Idle, opt.IdleClose)
+	c.setHeartbeat(opt.HeartbeatInterval, opt.HeartbeatTimeout)
+}
+
+func (c *idleConfig) set(read, write, all time.Duration, close bool) {
+	atomic.StoreInt64(&c.read, (int64)(read))
+	atomic.StoreInt64(&c.write, (int64)(write))
+	atomic.StoreInt64(&c.all, (int64)(all))
+	v := (int32)(0)
+	if close {
+		v = 1
+	}
+	atomic.StoreInt32(&c.close, v)
+	c.notify()
+}
+
+func (c *idleConfig) setHeartbeat(interval, timeout time.Duration) {
+	atomic.StoreInt64(&c.hbSend, (int64)(interval))
+	atomic.StoreInt64(&c.hbTimeout, (int64)(timeout))
+	c.notify()
+}
+
+func (c *idleConfig) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// tick 检查周期，取最小超时的一半，0表示不需要检查
+func (c *idleConfig) tick() time.Duration {
+	tick := (int64)(0)
+	for _, v := range []int64{
+		atomic.LoadInt64(&c.read), atomic.LoadInt64(&c.write), atomic.LoadInt64(&c.all),
+		atomic.LoadInt64(&c.hbSend), atomic.LoadInt64(&c.hbTimeout)} {
+		if v > 0 && (tick == 0 || v < tick) {
+			tick = v
+		}
+	}
+	if tick == 0 {
+		return 0
+	}
+	if tick/2 < (int64)(minIdleTick) {
+		return minIdleTick
+	}
+	return (time.Duration)(tick / 2)
+}
+
+// SetIdleTimeout 设置连接读、写、读写空闲时间，0为不检查，closeConn为true时空闲直接关闭连接
+func (c *Connection) SetIdleTimeout(read, write, all time.Duration, closeConn bool) {
+	c.idle.set(read, write, all, closeConn)
+}
+
+// SetHeartbeat 设置心跳，写空闲interval后发送心跳，timeout内没有读到数据则关闭连接
+func (c *Connection) SetHeartbeat(interval, timeout time.Duration) {
+	c.idle.setHeartbeat(interval, timeout)
+}
+
+func (c *Connection) touch() {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&c.readTime, now)
+	atomic.StoreInt64(&c.writeTime, now)
+}
+func (c *Connection) touchRead() {
+	atomic.StoreInt64(&c.readTime, time.Now().UnixNano())
+}
+func (c *Connection) touchWrite() {
+	atomic.StoreInt64(&c.writeTime, time.Now().UnixNano())
+}
+
+func (n *SimpleNet) handleIdle(conn *Connection, sock net.Conn, done chan struct{}) {
+	defer func() {
+		err := recover()
+		if err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("handleIdle panic: %s\n", err))
+		}
+	}()
+	var fired [IdleAll + 1]int64
+	for {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if tick := conn.idle.tick(); tick > 0 {
+			timer = time.NewTimer(tick)
+			timeout = timer.C
+		}
+		select {
+		case <-done:
+		case <-conn.idle.changed:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		now := time.Now().UnixNano()
+		r, w := atomic.LoadInt64(&conn.readTime), atomic.LoadInt64(&conn.writeTime)
+
+		if d := atomic.LoadInt64(&conn.idle.hbTimeout); d > 0 && now-r >= d {
+			n.checkConnErr(0, ErrHeartbeatTimeout, conn, sock)
+			return
+		}
+		if d := atomic.LoadInt64(&conn.idle.hbSend); d > 0 && now-w >= d {
+			n.sendHeartbeat(conn)
+		}
+
+		last := r
+		if w > last {
+			last = w
+		}
+		checks := [IdleAll + 1][2]int64{
+			IdleRead:  {atomic.LoadInt64(&conn.idle.read), r},
+			IdleWrite: {atomic.LoadInt64(&conn.idle.write), w},
+			IdleAll:   {atomic.LoadInt64(&conn.idle.all), last},
+		}
+		for idle := IdleRead; idle <= IdleAll; idle++ {
+			d, t := checks[idle][0], checks[idle][1]
+			if fired[idle] > t {
+				t = fired[idle]
+			}
+			if d <= 0 || now-t < d {
+				continue
+			}
+			if atomic.LoadInt32(&conn.idle.close) != 0 {
+				n.checkConnErr(0, ErrIdleTimeout, conn, sock)
+				return
+			}
+			fired[idle] = now
+			n.emit(&ConnEvent{
+				EventType: EventIdle,
+				Conn:      conn,
+				Data:      idle,
+			})
+		}
+	}
+}
+
+func (n *SimpleNet) sendHeartbeat(conn *Connection) {
+	hb, ok := conn.proto.(IHeartbeat)
+	if !ok {
+		return
+	}
+	msg, err := hb.Heartbeat(conn)
+	if err == nil {
+		err = n.TrySendData(conn, msg)
+	}
+	if err != nil {
+		n.logMsg(mylog.LevelError,
+			fmt.Sprintf("send heartbeat failed, err = %s\n", err))
+	}
+}
+
+// handleHeartbeat 心跳包由框架处理，返回true表示data已被处理
+func (n *SimpleNet) handleHeartbeat(conn *Connection, data interface{}) bool {
+	hb, ok := conn.proto.(IHeartbeat)
+	if !ok {
+		return false
+	}
+	reply, ok := hb.HeartbeatReply(conn, data)
+	if !ok {
+		return false
+	}
+	if reply != nil {
+		if err := n.TrySendData(conn, reply); err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("send heartbeat reply failed, err = %s\n", err))
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type idleHandler struct {
+	*testHandler
+	idle chan int
+}
+
+func (h *idleHandler) OnIdle(conn *Connection, idle int) {
+	h.idle <- idle
+}
+
+// pingProto 在lenProto基础上实现IHeartbeat
+type pingProto struct {
+	lenProto
+}
+
+func (p *pingProto) Heartbeat(conn *Connection) (interface{}, error) {
+	return []byte("ping"), nil
+}
+func (p *pingProto) HeartbeatReply(conn *Connection, data interface{}) (interface{}, bool) {
+	switch string(data.([]byte)) {
+	case "ping":
+		return []byte("pong"), true
+	case "pong":
+		return nil, true
+	}
+	return nil, false
+}
+
+func TestIdleEvent(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := &idleHandler{testHandler: newTestHandler(), idle: make(chan int, 16)}
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0", nil,
+		&Options{Handler: server, ReadIdle: time.Millisecond * 50})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	client := newTestHandler()
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), nil, client)
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	select {
+	case idle := <-server.idle:
+		if idle != IdleRead {
+			t.Fatalf("idle type = %d, expect read idle", idle)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("idle event timeout")
+	}
+
+	// 单个连接改为空闲关闭
+	conn.SetIdleTimeout(0, time.Millisecond*50, 0, true)
+	select {
+	case err = <-client.close:
+		if !errors.Is(err, ErrIdleTimeout) {
+			t.Fatalf("expect ErrIdleTimeout, err = %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("idle close timeout")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0", &pingProto{},
+		&Options{
+			Handler:           server,
+			HeartbeatInterval: time.Millisecond * 20,
+			HeartbeatTimeout:  time.Millisecond * 100,
+		})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	client := newTestHandler()
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), &pingProto{}, client)
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	// 客户端自动应答，服务端不会超时
+	select {
+	case err = <-server.close:
+		t.Fatalf("heartbeat should keep connection alive, err = %v", err)
+	case b := <-server.data:
+		t.Fatalf("heartbeat should not deliver as data, got = %s", b)
+	case b := <-client.data:
+		t.Fatalf("heartbeat should not deliver as data, got = %s", b)
+	case <-time.After(time.Millisecond * 500):
+	}
+	if conn.Status() != StatusConnected {
+		t.Fatalf("connection status = %d, expect connected", conn.Status())
+	}
+
+	// 普通数据照常投递
+	if err = n.SendData(conn, []byte("data")); err != nil {
+		t.Fatalf("send failed, err = %s", err)
+	}
+	select {
+	case b := <-server.data:
+		if string(b) != "data" {
+			t.Fatalf("data not match, got = %s", b)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive timeout")
+	}
+
+	// 不应答心跳的客户端会被关闭
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	defer sock.Close()
+	select {
+	case err = <-server.close:
+		if !errors.Is(err, ErrHeartbeatTimeout) {
+			t.Fatalf("expect ErrHeartbeatTimeout, err = %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("heartbeat timeout not closed")
+	}
+}
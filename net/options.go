@@ -0,0 +1,158 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+const (
+	defHandshakeTimeout        = time.Second * 10
+	defReadBufferSize          = 4096
+	defMaxFrameSize     uint32 = 16 * 1024 * 1024
+	defWriteBatchSize          = 64 * 1024
+	defPeerIdle                = time.Second * 60
+	maxWriteBuffers            = 1024 // IOV_MAX
+)
+
+// Options 监听/连接参数，accept的连接沿用Listener的参数，nil使用默认值
+type Options struct {
+	// Network 网络类型tcp/unix/udp等，地址带有unix://之类前缀时以前缀为准，默认tcp
+	Network string
+	// DialTimeout 连接超时，0为不超时
+	DialTimeout time.Duration
+	// KeepAlive tcp keepalive周期，0为系统默认，负数关闭
+	KeepAlive time.Duration
+	// LocalAddr 主动连接时绑定的本地地址
+	LocalAddr string
+	// TLSConfig 不为nil时使用tls，服务端需配置证书
+	TLSConfig *tls.Config
+	// HandshakeTimeout tls握手超时，默认10s
+	HandshakeTimeout time.Duration
+
+	// Reconnect 主动连接断线重连策略，nil不重连
+	Reconnect *ReconnectPolicy
+
+	// ReadBufferSize 读缓冲大小，没有proto时也是单次投递数据的最大长度，默认4096
+	ReadBufferSize int
+	// MaxFrameSize 包体最大长度，超过则按包头错误处理，默认16M
+	MaxFrameSize uint32
+	// ProtoError 包头或包体解析失败时的策略，默认ProtoErrorContinue
+	ProtoError int
+	// MaxProtoErrors 累计解析失败超过该次数断开连接，0不限制
+	MaxProtoErrors int
+	// ResyncSkip ProtoErrorResync时从出错的包头开始跳过的字节数，默认1
+	ResyncSkip int
+	// ResyncMagic ProtoErrorResync时跳过ResyncSkip字节后，再跳到下一个以该值开始的位置
+	ResyncMagic []byte
+
+	// ReadIdle/WriteIdle/Idle 读、写、读写空闲时间，超过触发EventIdle，0不检查
+	ReadIdle  time.Duration
+	WriteIdle time.Duration
+	Idle      time.Duration
+	// IdleClose 空闲时直接关闭连接，不触发EventIdle
+	IdleClose bool
+	// HeartbeatInterval 写空闲超过该时间自动发送心跳，proto需实现IHeartbeat
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout 超过该时间没有读到任何数据则关闭连接
+	HeartbeatTimeout time.Duration
+
+	// PeerIdle udp监听的对端超过该时间没有数据报则关闭，默认60s，负数不关闭
+	PeerIdle time.Duration
+	// MaxPeers udp监听同时存在的对端数上限，超过时丢弃新对端的数据报，0不限制
+	MaxPeers int
+
+	// SendQueueSize 发送队列最大消息数，默认1024
+	SendQueueSize int
+	// SendQueueBytes 发送队列最大字节数，0不限制
+	SendQueueBytes int
+	// Overflow 发送队列满时的策略，默认OverflowBlock
+	Overflow int
+	// WriteBatchSize 合并写的最大字节数，默认64K，负数不合并；udp不合并
+	WriteBatchSize int
+
+	// Handler 事件回调，nil则事件进入PollEvent队列
+	Handler Handler
+	// ReleaseData OnData返回后回收data引用的读缓冲，OnData返回后不能再引用data
+	ReleaseData bool
+}
+
+func (o *Options) dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+	d := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.KeepAlive,
+	}
+	if o.LocalAddr != "" {
+		local, err := resolveAddr(network, o.LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+		d.LocalAddr = local
+	}
+	sock, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if o.TLSConfig != nil {
+		tlsconn, err := o.clientHandshake(ctx, sock, addr)
+		if err != nil {
+			sock.Close()
+			return nil, err
+		}
+		sock = tlsconn
+	}
+	return sock, nil
+}
+
+func (o *Options) listenConfig() *net.ListenConfig {
+	return &net.ListenConfig{
+		KeepAlive: o.KeepAlive,
+	}
+}
+
+func (o *Options) readBufferSize() int {
+	if o.ReadBufferSize <= 0 {
+		return defReadBufferSize
+	}
+	return o.ReadBufferSize
+}
+
+func (o *Options) maxFrameSize() uint32 {
+	if o.MaxFrameSize == 0 {
+		return defMaxFrameSize
+	}
+	return o.MaxFrameSize
+}
+
+func (o *Options) peerIdle() time.Duration {
+	if o.PeerIdle == 0 {
+		return defPeerIdle
+	}
+	if o.PeerIdle < 0 {
+		return 0
+	}
+	return o.PeerIdle
+}
+
+func copyOptions(opt *Options) *Options {
+	o := &Options{}
+	if opt != nil {
+		*o = *opt
+	}
+	return o
+}
+
+// watchContext ctx取消时调用cancel，closed关闭时退出
+func watchContext(ctx context.Context, closed chan struct{}, cancel func()) {
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-closed:
+		}
+	}()
+}
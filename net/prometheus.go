@@ -0,0 +1,71 @@
+package net
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promMetrics 按Listener输出的指标
+var promMetrics = []struct {
+	name  string
+	typ   string
+	help  string
+	value func(s *ListenerStats) int64
+}{
+	{"simplenet_connections", "gauge", "Current connections.",
+		func(s *ListenerStats) int64 { return (int64)(s.Connections) }},
+	{"simplenet_connects_total", "counter", "Connections established, including reconnects.",
+		func(s *ListenerStats) int64 { return s.Connects }},
+	{"simplenet_bytes_in_total", "counter", "Bytes read from sockets.",
+		func(s *ListenerStats) int64 { return s.BytesIn }},
+	{"simplenet_bytes_out_total", "counter", "Bytes written to sockets.",
+		func(s *ListenerStats) int64 { return s.BytesOut }},
+	{"simplenet_frames_in_total", "counter", "Frames received.",
+		func(s *ListenerStats) int64 { return s.FramesIn }},
+	{"simplenet_frames_out_total", "counter", "Frames sent.",
+		func(s *ListenerStats) int64 { return s.FramesOut }},
+	{"simplenet_proto_errors_total", "counter", "Protocol errors.",
+		func(s *ListenerStats) int64 { return s.ProtoErrors }},
+	{"simplenet_queue_messages", "gauge", "Messages waiting in send queues.",
+		func(s *ListenerStats) int64 { return (int64)(s.QueueLen) }},
+	{"simplenet_queue_bytes", "gauge", "Bytes waiting in send queues.",
+		func(s *ListenerStats) int64 { return (int64)(s.QueueBytes) }},
+}
+
+// WritePrometheus 以Prometheus文本格式输出，每个Listener一组，
+// 主动连接的汇总listener标签为outbound
+func (s *Stats) WritePrometheus(w io.Writer) error {
+	groups := make([]ListenerStats, 0, len(s.Listeners)+1)
+	groups = append(groups, s.Listeners...)
+	groups = append(groups, s.Outbound)
+
+	bw := bufio.NewWriter(w)
+	for _, m := range promMetrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for i := range groups {
+			g := &groups[i]
+			id := "outbound"
+			if g.ID != 0 {
+				id = strconv.FormatInt(g.ID, 10)
+			}
+			fmt.Fprintf(bw, "%s{listener=\"%s\",network=\"%s\",addr=\"%s\"} %d\n",
+				m.name, id, promLabelEscaper.Replace(g.Network),
+				promLabelEscaper.Replace(g.Addr), m.value(g))
+		}
+	}
+	return bw.Flush()
+}
+
+// MetricsHandler 输出Stats的http.Handler，如http.Handle("/metrics", n.MetricsHandler())
+func (n *SimpleNet) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		n.Stats().WritePrometheus(w)
+	})
+}
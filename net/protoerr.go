@@ -0,0 +1,95 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// 解析失败时的策略，包头出错指IProto.BodyLen失败或包体超过MaxFrameSize，
+// 包体出错指Parse失败，此时数据仍然是对齐的
+const (
+	// ProtoErrorContinue 跳过出错的包头或包体继续读取，包体超长无法跳过时断开
+	ProtoErrorContinue = iota
+	// ProtoErrorClose 断开连接
+	ProtoErrorClose
+	// ProtoErrorResync 包头出错时跳过ResyncSkip字节，再跳到ResyncMagic处重新读取包头；
+	// 包体出错同ProtoErrorContinue
+	ProtoErrorResync
+)
+
+// ProtoErrors 累计解析失败的次数
+func (c *Connection) ProtoErrors() int64 {
+	return atomic.LoadInt64(&c.stat.protoErrors)
+}
+
+func (n *SimpleNet) emitProtoError(conn *Connection, err error) int64 {
+	// emit EventProtoError
+	event := &ConnEvent{
+		EventType: EventProtoError,
+		Conn:      conn,
+		Data:      err,
+	}
+	n.emit(event)
+	return conn.addProtoError()
+}
+
+// protoError 投递EventProtoError并按Options.ProtoError处理，返回false表示连接已断开。
+// head为reader中未消费的出错包头长度，0表示包体出错
+func (n *SimpleNet) protoError(conn *Connection, sock net.Conn, reader *bufio.Reader, err error, head int) bool {
+	count := n.emitProtoError(conn, err)
+
+	opt := conn.opt
+	var closeErr error
+	switch {
+	case opt.ProtoError == ProtoErrorClose:
+		closeErr = err
+	case opt.MaxProtoErrors > 0 && count > (int64)(opt.MaxProtoErrors):
+		n.logMsg(mylog.LevelError, fmt.Sprintf("too many proto errors, count = %d, remoteAddr: = %s\n",
+			count, sock.RemoteAddr()))
+		closeErr = err
+	case head == 0:
+	case opt.ProtoError == ProtoErrorResync:
+		closeErr = resync(reader, opt.ResyncSkip, opt.ResyncMagic)
+	case errors.Is(err, ErrFrameTooLarge):
+		// 长度不可信，后续数据无法对齐，只能断开
+		closeErr = err
+	default:
+		_, closeErr = reader.Discard(head)
+	}
+	if closeErr != nil {
+		n.checkConnErr(0, closeErr, conn, sock)
+		return false
+	}
+	return true
+}
+
+// resync 跳过skip字节，再跳到下一个magic出现的位置
+func resync(reader *bufio.Reader, skip int, magic []byte) error {
+	if skip <= 0 {
+		skip = 1
+	}
+	if _, err := reader.Discard(skip); err != nil {
+		return err
+	}
+	if len(magic) == 0 {
+		return nil
+	}
+	for {
+		b, err := reader.Peek(max(len(magic), reader.Buffered()))
+		if i := bytes.Index(b, magic); i >= 0 {
+			reader.Discard(i)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 保留末尾可能是magic前缀的部分
+		reader.Discard(len(b) - len(magic) + 1)
+	}
+}
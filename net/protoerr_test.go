@@ -0,0 +1,170 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// magicProto 2字节魔数MG + 2字节长度 + 包体，包体为bad时解析失败
+type magicProto struct{}
+
+func (p *magicProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+func (p *magicProto) HeadLen() uint32 {
+	return 4
+}
+func (p *magicProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	if string(head[:2]) != "MG" {
+		return nil, 0, fmt.Errorf("bad magic %q", head[:2])
+	}
+	return nil, (uint32)(binary.BigEndian.Uint16(head[2:])), nil
+}
+func (p *magicProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	if string(body) == "bad" {
+		return nil, fmt.Errorf("bad body")
+	}
+	return body, nil
+}
+func (p *magicProto) Serialize(data interface{}) ([]byte, error) {
+	body := data.([]byte)
+	buf := append([]byte("MG"), 0, 0)
+	binary.BigEndian.PutUint16(buf[2:], (uint16)(len(body)))
+	return append(buf, body...), nil
+}
+
+type protoErrHandler struct {
+	*testHandler
+	proto chan error
+}
+
+func (h *protoErrHandler) OnProtoError(conn *Connection, err error) {
+	h.proto <- err
+}
+
+func dialMagic(t *testing.T, n *SimpleNet, opt *Options) (*protoErrHandler, net.Conn) {
+	h := &protoErrHandler{testHandler: newTestHandler(), proto: make(chan error, 16)}
+	opt.Handler = h
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0", &magicProto{}, opt)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	return h, sock
+}
+
+func magicFrame(body string) []byte {
+	b, _ := (&magicProto{}).Serialize([]byte(body))
+	return b
+}
+
+func expectProtoErr(t *testing.T, h *protoErrHandler, target error) {
+	select {
+	case err := <-h.proto:
+		if target != nil && !errors.Is(err, target) {
+			t.Fatalf("expect %s, err = %v", target, err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("proto error timeout")
+	}
+}
+
+func expectData(t *testing.T, h *protoErrHandler, expect string) {
+	select {
+	case b := <-h.data:
+		if string(b) != expect {
+			t.Fatalf("data not match, got = %s, expect = %s", b, expect)
+		}
+	case err := <-h.close:
+		t.Fatalf("connection closed, err = %v", err)
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive timeout")
+	}
+}
+
+func expectClose(t *testing.T, h *protoErrHandler) {
+	select {
+	case <-h.close:
+	case b := <-h.data:
+		t.Fatalf("unexpected data %s", b)
+	case <-time.After(time.Second * 5):
+		t.Fatalf("connection not closed")
+	}
+}
+
+func TestProtoErrorContinue(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	h, sock := dialMagic(t, n, &Options{})
+	defer sock.Close()
+
+	// 包体出错跳过该包，包头出错跳过该包头
+	sock.Write(magicFrame("bad"))
+	sock.Write([]byte("XXXX"))
+	sock.Write(magicFrame("good"))
+	expectProtoErr(t, h, nil)
+	expectProtoErr(t, h, ErrBadHeader)
+	expectData(t, h, "good")
+}
+
+func TestProtoErrorClose(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	h, sock := dialMagic(t, n, &Options{ProtoError: ProtoErrorClose})
+	defer sock.Close()
+
+	sock.Write(magicFrame("bad"))
+	sock.Write(magicFrame("good"))
+	expectProtoErr(t, h, nil)
+	expectClose(t, h)
+}
+
+func TestProtoErrorResync(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	h, sock := dialMagic(t, n, &Options{
+		ProtoError:   ProtoErrorResync,
+		MaxFrameSize: 64,
+		ResyncMagic:  []byte("MG"),
+	})
+	defer sock.Close()
+
+	// 错位的数据，跳到下一个魔数处
+	sock.Write(append([]byte("xyz"), magicFrame("first")...))
+	expectProtoErr(t, h, ErrBadHeader)
+	expectData(t, h, "first")
+
+	// 长度不可信时同样重新对齐
+	sock.Write([]byte{'M', 'G', 0xff, 0xff})
+	sock.Write(magicFrame("second"))
+	expectProtoErr(t, h, ErrFrameTooLarge)
+	expectData(t, h, "second")
+}
+
+func TestMaxProtoErrors(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	h, sock := dialMagic(t, n, &Options{MaxProtoErrors: 2})
+	defer sock.Close()
+
+	for i := 0; i < 3; i++ {
+		sock.Write(magicFrame("bad"))
+	}
+	sock.Write(magicFrame("good"))
+	for i := 0; i < 3; i++ {
+		expectProtoErr(t, h, nil)
+	}
+	expectClose(t, h)
+}
@@ -0,0 +1,199 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// Options.Overflow 发送队列满时的策略
+const (
+	// OverflowBlock 等待队列有空间，TrySendData返回ErrQueueFull
+	OverflowBlock = iota
+	// OverflowDropOldest 丢弃最早的数据
+	OverflowDropOldest
+	// OverflowClose 关闭连接
+	OverflowClose
+)
+
+const (
+	defSendQueueSize = 1024
+)
+
+// sendQueue 有消息数及字节数上限的发送队列，不关闭，写协程通过ready等待数据
+type sendQueue struct {
+	lock     sync.Mutex
+	msgs     []*outMsg
+	bytes    int
+	maxMsgs  int
+	maxBytes int
+	space    chan struct{} // 有空间时关闭，通知等待的发送方
+	ready    chan struct{}
+}
+
+func newSendQueue(opt *Options) *sendQueue {
+	q := &sendQueue{
+		maxMsgs:  opt.SendQueueSize,
+		maxBytes: opt.SendQueueBytes,
+		ready:    make(chan struct{}, 1),
+	}
+	if q.maxMsgs <= 0 {
+		q.maxMsgs = defSendQueueSize
+	}
+	return q
+}
+
+// fits 调用方持有锁，队列为空时总能放入，避免超过字节上限的数据永远发不出去
+func (q *sendQueue) fits(msg *outMsg) bool {
+	if len(q.msgs) == 0 {
+		return true
+	}
+	if len(q.msgs) >= q.maxMsgs {
+		return false
+	}
+	return q.maxBytes <= 0 || q.bytes+len(msg.data) <= q.maxBytes
+}
+
+// push 放入队列，队列满时返回等待空间的channel；dropOldest为true时丢弃最早的数据腾出空间
+func (q *sendQueue) push(msg *outMsg, dropOldest bool) (dropped int, space <-chan struct{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for !q.fits(msg) {
+		if !dropOldest {
+			if q.space == nil {
+				q.space = make(chan struct{})
+			}
+			return dropped, q.space
+		}
+		q.popLocked().release()
+		dropped++
+	}
+	q.msgs = append(q.msgs, msg)
+	q.bytes += len(msg.data)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return dropped, nil
+}
+
+func (q *sendQueue) popLocked() *outMsg {
+	msg := q.msgs[0]
+	q.msgs[0] = nil
+	q.msgs = q.msgs[1:]
+	if len(q.msgs) == 0 {
+		q.msgs = nil
+	}
+	q.bytes -= len(msg.data)
+	if q.space != nil {
+		close(q.space)
+		q.space = nil
+	}
+	return msg
+}
+
+func (q *sendQueue) pop() (*outMsg, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.msgs) == 0 {
+		return nil, false
+	}
+	return q.popLocked(), true
+}
+
+// popBatch 取出至少一个包追加到batch，总长度不超过maxBytes、个数不超过maxMsgs
+func (q *sendQueue) popBatch(batch []*outMsg, maxBytes int, maxMsgs int) []*outMsg {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	size := 0
+	for len(q.msgs) > 0 && len(batch) < maxMsgs {
+		if len(batch) > 0 && size+len(q.msgs[0].data) > maxBytes {
+			break
+		}
+		msg := q.popLocked()
+		size += len(msg.data)
+		batch = append(batch, msg)
+	}
+	return batch
+}
+
+// len 队列中的消息数及字节数
+func (q *sendQueue) len() (int, int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.msgs), q.bytes
+}
+
+// QueueLen 发送队列中等待发送的消息数及字节数
+func (c *Connection) QueueLen() (int, int) {
+	return c.queue.len()
+}
+
+// TrySendData 不阻塞的SendData，队列满时按Options.Overflow处理，OverflowBlock返回ErrQueueFull
+func (n *SimpleNet) TrySendData(conn *Connection, data interface{}) error {
+	return n.sendData(context.Background(), conn, data, false)
+}
+
+// SendDataContext 同SendData，队列满时最多等待到ctx结束并返回ctx.Err()
+func (n *SimpleNet) SendDataContext(ctx context.Context, conn *Connection, data interface{}) error {
+	return n.sendData(ctx, conn, data, true)
+}
+
+// sendData block为false时队列满不等待
+func (n *SimpleNet) sendData(ctx context.Context, conn *Connection, data interface{}, block bool) error {
+	if err := conn.checkSend(); err != nil {
+		return err
+	}
+	msg, err := serialize(conn.proto, data, &conn.sizeHint)
+	if err != nil {
+		return err
+	}
+	return n.sendMsg(ctx, conn, msg, block)
+}
+
+// sendMsg 把已经序列化的数据放入发送队列，msg发送前不能被修改。
+// sendMsg持有msg的一个引用，失败时释放
+func (n *SimpleNet) sendMsg(ctx context.Context, conn *Connection, msg *outMsg, block bool) (err error) {
+	defer func() {
+		if err != nil {
+			msg.release()
+		}
+	}()
+	for {
+		if err := conn.checkSend(); err != nil {
+			return err
+		}
+		dropped, space := conn.queue.push(msg, conn.opt.Overflow == OverflowDropOldest)
+		if dropped > 0 {
+			n.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("send queue full, drop %d message(s), remoteAddr = %s\n",
+					dropped, conn.RemoteAddress()))
+		}
+		if space == nil {
+			return nil
+		}
+		if conn.opt.Overflow == OverflowClose {
+			n.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("send queue full, close connection, remoteAddr = %s\n",
+					conn.RemoteAddress()))
+			n.closeConnWithErr(conn, ErrQueueFull)
+			return ErrQueueFull
+		}
+		if !block {
+			return ErrQueueFull
+		}
+		select {
+		case <-space:
+		case <-conn.closed:
+			return ErrNotConnected
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
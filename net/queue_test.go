@@ -0,0 +1,143 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendQueueLimit(t *testing.T) {
+	q := newSendQueue(&Options{SendQueueSize: 2, SendQueueBytes: 8})
+
+	if _, space := q.push(newOutMsg([]byte("12345"), false), false); space != nil {
+		t.Fatalf("push to empty queue failed")
+	}
+	// 超过字节上限
+	if _, space := q.push(newOutMsg([]byte("6789"), false), false); space == nil {
+		t.Fatalf("push should exceed byte limit")
+	}
+	if _, space := q.push(newOutMsg([]byte("678"), false), false); space != nil {
+		t.Fatalf("push within limit failed")
+	}
+	// 超过消息数上限，丢弃最早的数据
+	dropped, space := q.push(newOutMsg([]byte("9"), false), true)
+	if space != nil || dropped != 1 {
+		t.Fatalf("drop oldest failed, dropped = %d", dropped)
+	}
+	if count, bytes := q.len(); count != 2 || bytes != 4 {
+		t.Fatalf("queue len = %d, bytes = %d", count, bytes)
+	}
+	for _, expect := range []string{"678", "9"} {
+		msg, ok := q.pop()
+		if !ok || string(msg.data) != expect {
+			t.Fatalf("pop = %s, expect %s", msg.data, expect)
+		}
+	}
+	// 空队列总能放入，即使超过字节上限
+	if _, space := q.push(newOutMsg(make([]byte, 16), false), false); space != nil {
+		t.Fatalf("large message should fit empty queue")
+	}
+}
+
+// slowPeer 接受连接但不读取数据
+func slowPeer(t *testing.T) (net.Listener, chan net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		sock, err := ln.Accept()
+		if err == nil {
+			accepted <- sock
+		}
+	}()
+	return ln, accepted
+}
+
+// fillQueue 一直发送到发送队列满
+func fillQueue(t *testing.T, n *SimpleNet, conn *Connection) error {
+	chunk := make([]byte, 256*1024)
+	for i := 0; i < 4096; i++ {
+		if err := n.TrySendData(conn, chunk); err != nil {
+			return err
+		}
+	}
+	t.Fatalf("send queue never full")
+	return nil
+}
+
+func TestSendDataBackpressure(t *testing.T) {
+	ln, accepted := slowPeer(t)
+	defer ln.Close()
+
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	conn, err := n.ConnectContext(context.Background(), ln.Addr().String(), nil,
+		&Options{Handler: &NopHandler{}, SendQueueSize: 4})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	sock := <-accepted
+	defer sock.Close()
+
+	if err = fillQueue(t, n, conn); err != ErrQueueFull {
+		t.Fatalf("expect ErrQueueFull, err = %v", err)
+	}
+	// 写协程可能还在往内核缓冲写，多试几次直到队列不再腾出空间
+	for i := 0; ; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		err = n.SendDataContext(ctx, conn, make([]byte, 256*1024))
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil || i > 100 {
+			t.Fatalf("expect deadline exceeded, err = %v", err)
+		}
+	}
+
+	// 对端开始读取后可以继续发送
+	go io.Copy(io.Discard, sock)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err = n.SendDataContext(ctx, conn, []byte("wait")); err != nil {
+		t.Fatalf("send after drain failed, err = %s", err)
+	}
+}
+
+func TestSendQueueOverflowClose(t *testing.T) {
+	ln, accepted := slowPeer(t)
+	defer ln.Close()
+
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	client := newTestHandler()
+	conn, err := n.ConnectContext(context.Background(), ln.Addr().String(), nil,
+		&Options{Handler: client, SendQueueSize: 4, Overflow: OverflowClose})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	sock := <-accepted
+	defer sock.Close()
+
+	if err = fillQueue(t, n, conn); err != ErrQueueFull {
+		t.Fatalf("expect ErrQueueFull, err = %v", err)
+	}
+	if conn.Status() != StatusBroken {
+		t.Fatalf("connection status = %d, expect broken", conn.Status())
+	}
+	select {
+	case err = <-client.close:
+		if err != ErrQueueFull {
+			t.Fatalf("expect ErrQueueFull, err = %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("OnError timeout")
+	}
+}
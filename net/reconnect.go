@@ -0,0 +1,148 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const (
+	defReconnectInitial    = time.Second
+	defReconnectMax        = time.Second * 30
+	defReconnectMultiplier = 2.0
+)
+
+// ReconnectPolicy 断线重连策略，指数退避加随机抖动
+type ReconnectPolicy struct {
+	// InitialInterval 第一次重连前等待时间，默认1s
+	InitialInterval time.Duration
+	// MaxInterval 最大等待时间，默认30s
+	MaxInterval time.Duration
+	// Multiplier 每次等待时间的倍数，默认2
+	Multiplier float64
+	// Jitter 随机抖动比例(0~1)，0不抖动
+	Jitter float64
+	// MaxAttempts 最大重连次数，0不限次数
+	MaxAttempts int
+	// RejectWhileReconnecting 重连期间SendData返回ErrReconnecting，否则缓存到发送队列
+	RejectWhileReconnecting bool
+}
+
+// ReconnectHandler Handler可选实现，接收重连事件
+type ReconnectHandler interface {
+	OnReconnecting(conn *Connection, err error)
+	OnReconnected(conn *Connection)
+}
+
+// backoff 第attempt次重连前的等待时间，attempt从1开始
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	initial, max, mul := p.InitialInterval, p.MaxInterval, p.Multiplier
+	if initial <= 0 {
+		initial = defReconnectInitial
+	}
+	if max <= 0 {
+		max = defReconnectMax
+	}
+	if mul < 1 {
+		mul = defReconnectMultiplier
+	}
+
+	wait := float64(initial)
+	for i := 1; i < attempt && wait < float64(max); i++ {
+		wait *= mul
+	}
+	if wait > float64(max) {
+		wait = float64(max)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(wait)
+}
+
+// startReconnect 调用方持有conn.lock，返回false表示不需要重连或者连接已经关闭
+func (n *SimpleNet) startReconnect(conn *Connection, err error) bool {
+	if conn.listen != nil || conn.opt.Reconnect == nil {
+		return false
+	}
+	if !conn.casStatus(StatusConnected, StatusReconnecting) {
+		return false
+	}
+	close(conn.sockDone)
+	conn.conn.Close()
+	// 已经发出的请求不会在新的socket上应答
+	conn.failCalls(ErrReconnecting)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.reconnect(conn, err)
+	}()
+
+	return true
+}
+
+func (n *SimpleNet) reconnect(conn *Connection, cause error) {
+	n.logMsg(mylog.LevelNotice,
+		fmt.Sprintf("connection reconnecting, addr = %s, err = %s\n", conn.addr, cause))
+
+	n.emit(&ConnEvent{
+		EventType: EventReconnecting,
+		Conn:      conn,
+		Data:      cause,
+	})
+
+	policy := conn.opt.Reconnect
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-conn.closed:
+			return
+		}
+		sock, err := conn.opt.dial(conn.ctx, conn.network, conn.addr)
+		if err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("reconnect failed, addr = %s, attempt = %d, err = %s\n",
+					conn.addr, attempt, err))
+			cause = err
+			continue
+		}
+
+		conn.lock.Lock()
+		if !conn.casStatus(StatusReconnecting, StatusConnected) {
+			// 重连期间被关闭
+			conn.lock.Unlock()
+			sock.Close()
+			return
+		}
+		conn.conn = sock
+		conn.sockDone = make(chan struct{})
+		conn.localAddr = sock.LocalAddr().String()
+		conn.remoteAddr = sock.RemoteAddr().String()
+		conn.touch()
+		conn.connected()
+		// 持有锁预留，保证Shutdown能等到新的写协程
+		n.reserveIO()
+		conn.lock.Unlock()
+
+		n.logMsg(mylog.LevelNotice,
+			fmt.Sprintf("connection reconnected, addr = %s, attempt = %d\n",
+				conn.addr, attempt))
+
+		n.emit(&ConnEvent{
+			EventType: EventReconnected,
+			Conn:      conn,
+		})
+		n.startIO(conn)
+		return
+	}
+
+	// 超过最大重连次数
+	if !n.breakConn(conn) {
+		return
+	}
+	n.notifyClosed(context.Background(), conn, EventConnectionError, cause)
+}
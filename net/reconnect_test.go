@@ -0,0 +1,151 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type reconnectHandler struct {
+	*testHandler
+	reconnecting chan error
+	reconnected  chan *Connection
+}
+
+func (h *reconnectHandler) OnReconnecting(conn *Connection, err error) {
+	h.reconnecting <- err
+}
+func (h *reconnectHandler) OnReconnected(conn *Connection) {
+	h.reconnected <- conn
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	p := &ReconnectPolicy{
+		InitialInterval: time.Millisecond * 100,
+		MaxInterval:     time.Millisecond * 500,
+	}
+	expect := []time.Duration{100, 200, 400, 500, 500}
+	for i, v := range expect {
+		if wait := p.backoff(i + 1); wait != v*time.Millisecond {
+			t.Fatalf("attempt %d backoff = %s, expect %s", i+1, wait, v*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait := p.backoff(1)
+		if wait < time.Millisecond*50 || wait > time.Millisecond*150 {
+			t.Fatalf("jitter backoff out of range, wait = %s", wait)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenWithHandler("127.0.0.1:0", nil, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	client := &reconnectHandler{
+		testHandler:  newTestHandler(),
+		reconnecting: make(chan error, 16),
+		reconnected:  make(chan *Connection, 16),
+	}
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), nil,
+		&Options{
+			Handler: client,
+			Reconnect: &ReconnectPolicy{
+				InitialInterval: time.Millisecond * 10,
+				MaxInterval:     time.Millisecond * 50,
+				MaxAttempts:     10,
+			},
+		})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	conn.UserData = "user data"
+	id := conn.ID()
+
+	peer := <-server.connect
+	n.CloseConn(peer)
+
+	select {
+	case <-client.reconnecting:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("OnReconnecting timeout")
+	}
+	var re *Connection
+	select {
+	case re = <-client.reconnected:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("OnReconnected timeout")
+	}
+	if re != conn || re.ID() != id || re.UserData != "user data" {
+		t.Fatalf("reconnected connection not the same")
+	}
+	peer = <-server.connect
+
+	if err = n.SendData(conn, []byte("again")); err != nil {
+		t.Fatalf("send after reconnect failed, err = %s", err)
+	}
+	if got := recvString(t, server.data, len("again")); got != "again" {
+		t.Fatalf("data not match, got = %s", got)
+	}
+
+	// 服务器关闭后重连次数用完
+	n.CloseListen(listen)
+	select {
+	case <-client.reconnecting:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("OnReconnecting timeout")
+	}
+	select {
+	case <-client.close:
+	case <-time.After(time.Second * 30):
+		t.Fatalf("connection not closed after max attempts")
+	}
+	if conn.Status() != StatusBroken {
+		t.Fatalf("connection status = %d, expect broken", conn.Status())
+	}
+}
+
+func TestReconnectReject(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenWithHandler("127.0.0.1:0", nil, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	client := &reconnectHandler{
+		testHandler:  newTestHandler(),
+		reconnecting: make(chan error, 16),
+		reconnected:  make(chan *Connection, 16),
+	}
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), nil,
+		&Options{
+			Handler: client,
+			Reconnect: &ReconnectPolicy{
+				InitialInterval:         time.Second * 10,
+				RejectWhileReconnecting: true,
+			},
+		})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	n.CloseConn(<-server.connect)
+	<-client.reconnecting
+
+	if err = n.SendData(conn, []byte("reject")); err != ErrReconnecting {
+		t.Fatalf("send while reconnecting, err = %v", err)
+	}
+	n.CloseConn(conn)
+	if conn.Status() != StatusBroken {
+		t.Fatalf("connection status = %d, expect broken", conn.Status())
+	}
+}
@@ -0,0 +1,235 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RESPSimple RESP简单字符串，如+OK
+type RESPSimple string
+
+// RESPError RESP错误，如-ERR unknown command
+type RESPError string
+
+func (e RESPError) Error() string {
+	return (string)(e)
+}
+
+// maxRESPDepth 数组最大嵌套层数
+const maxRESPDepth = 32
+
+// RESPProto Redis RESP2协议。
+// 解码结果：简单字符串为RESPSimple，错误为RESPError，整数为int64，
+// 批量字符串为[]byte，数组为[]interface{}，空批量字符串和空数组为对应类型的nil。
+// 不以类型字节开头的行按内联命令解码为[]interface{}，元素为[]byte
+type RESPProto struct {
+	StreamBase
+}
+
+// NewRESPProto 创建
+func NewRESPProto() *RESPProto {
+	return &RESPProto{}
+}
+
+// Decode IDecoder，流水线发送的请求已经在缓冲中时一起返回
+func (p *RESPProto) Decode(r *bufio.Reader, maxFrame uint32) ([]interface{}, error) {
+	var msgs []interface{}
+	for {
+		v, ok, err := p.decode(r, maxFrame, 0)
+		if err != nil {
+			return msgs, err
+		}
+		if ok {
+			msgs = append(msgs, v)
+		}
+		if r.Buffered() == 0 {
+			return msgs, nil
+		}
+	}
+}
+
+// decode ok为false表示空的内联命令
+func (p *RESPProto) decode(r *bufio.Reader, maxFrame uint32, depth int) (interface{}, bool, error) {
+	line, err := readRESPLine(r, maxFrame)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(line) == 0 {
+		return nil, false, nil
+	}
+	switch line[0] {
+	case '+':
+		return (RESPSimple)(line[1:]), true, nil
+	case '-':
+		return (RESPError)(line[1:]), true, nil
+	case ':':
+		i, err := strconv.ParseInt((string)(line[1:]), 10, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: bad integer %q", ErrBadHeader, line)
+		}
+		return i, true, nil
+	case '$':
+		size, err := parseRESPLength(line, maxFrame)
+		if err != nil {
+			return nil, false, err
+		}
+		if size < 0 {
+			return ([]byte)(nil), true, nil
+		}
+		bulk := make([]byte, size+2)
+		if _, err = io.ReadFull(r, bulk); err != nil {
+			return nil, false, err
+		}
+		if !bytes.HasSuffix(bulk, []byte("\r\n")) {
+			return nil, false, fmt.Errorf("%w: bulk string not end with CRLF", ErrBadHeader)
+		}
+		return bulk[:size], true, nil
+	case '*':
+		if depth >= maxRESPDepth {
+			return nil, false, fmt.Errorf("%w: array nested too deep", ErrBadHeader)
+		}
+		size, err := parseRESPLength(line, maxFrame)
+		if err != nil {
+			return nil, false, err
+		}
+		if size < 0 {
+			return ([]interface{})(nil), true, nil
+		}
+		array := make([]interface{}, 0, min(size, 1024))
+		for len(array) < size {
+			v, ok, err := p.decode(r, maxFrame, depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				return nil, false, fmt.Errorf("%w: empty line in array", ErrBadHeader)
+			}
+			array = append(array, v)
+		}
+		return array, true, nil
+	}
+	// 内联命令
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+	cmd := make([]interface{}, len(fields))
+	for i, f := range fields {
+		cmd[i] = f
+	}
+	return cmd, true, nil
+}
+
+// readRESPLine 读取一行，返回的内容不包含行尾的\r\n，内联命令允许只有\n
+func readRESPLine(r *bufio.Reader, maxFrame uint32) ([]byte, error) {
+	var line []byte
+	for {
+		b, err := r.ReadSlice('\n')
+		if (uint64)(len(line)+len(b)) > (uint64)(maxFrame)+2 {
+			return nil, fmt.Errorf("%w: no line end in %d bytes", ErrFrameTooLarge, maxFrame)
+		}
+		line = append(line, b...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = line[:len(line)-1]
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		return line, nil
+	}
+}
+
+func parseRESPLength(line []byte, maxFrame uint32) (int, error) {
+	size, err := strconv.ParseInt((string)(line[1:]), 10, 64)
+	if err != nil || size < -1 {
+		return 0, fmt.Errorf("%w: bad length %q", ErrBadHeader, line)
+	}
+	if size > (int64)(maxFrame) {
+		return 0, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, size, maxFrame)
+	}
+	return (int)(size), nil
+}
+
+// Serialize IProto，支持nil、RESPSimple、RESPError、error、整数、[]byte、string、
+// []string和[]interface{}，string和[]byte按批量字符串编码
+func (p *RESPProto) Serialize(data interface{}) ([]byte, error) {
+	return appendRESP(nil, data)
+}
+
+// SerializeTo IBufferProto
+func (p *RESPProto) SerializeTo(buf []byte, data interface{}) ([]byte, error) {
+	return appendRESP(buf, data)
+}
+
+// RetainBody IBufferProto，Decode不使用缓冲池
+func (p *RESPProto) RetainBody() bool {
+	return true
+}
+
+func appendRESP(buf []byte, data interface{}) ([]byte, error) {
+	switch v := data.(type) {
+	case nil:
+		return append(buf, "$-1\r\n"...), nil
+	case RESPSimple:
+		return appendRESPLine(buf, '+', (string)(v))
+	case RESPError:
+		return appendRESPLine(buf, '-', (string)(v))
+	case error:
+		return appendRESPLine(buf, '-', v.Error())
+	case int:
+		return appendRESPInt(buf, ':', (int64)(v)), nil
+	case int64:
+		return appendRESPInt(buf, ':', v), nil
+	case []byte:
+		if v == nil {
+			return append(buf, "$-1\r\n"...), nil
+		}
+		buf = appendRESPInt(buf, '$', (int64)(len(v)))
+		return append(append(buf, v...), "\r\n"...), nil
+	case string:
+		buf = appendRESPInt(buf, '$', (int64)(len(v)))
+		return append(append(buf, v...), "\r\n"...), nil
+	case []string:
+		buf = appendRESPInt(buf, '*', (int64)(len(v)))
+		for _, s := range v {
+			buf = appendRESPInt(buf, '$', (int64)(len(s)))
+			buf = append(append(buf, s...), "\r\n"...)
+		}
+		return buf, nil
+	case []interface{}:
+		if v == nil {
+			return append(buf, "*-1\r\n"...), nil
+		}
+		buf = appendRESPInt(buf, '*', (int64)(len(v)))
+		for _, e := range v {
+			var err error
+			if buf, err = appendRESP(buf, e); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("resp: unsupported type %T", data)
+}
+
+func appendRESPLine(buf []byte, prefix byte, s string) ([]byte, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, fmt.Errorf("resp: line contains CR or LF")
+	}
+	buf = append(buf, prefix)
+	return append(append(buf, s...), "\r\n"...), nil
+}
+
+func appendRESPInt(buf []byte, prefix byte, i int64) []byte {
+	buf = append(buf, prefix)
+	buf = strconv.AppendInt(buf, i, 10)
+	return append(buf, "\r\n"...)
+}
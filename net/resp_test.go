@@ -0,0 +1,125 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestRESPRoundTrip(t *testing.T) {
+	values := []interface{}{
+		RESPSimple("OK"),
+		RESPError("ERR unknown command"),
+		int64(-42),
+		[]byte("bulk\r\nwith crlf"),
+		[]byte{},
+		([]byte)(nil),
+		[]interface{}{},
+		([]interface{})(nil),
+		[]interface{}{[]byte("SET"), []byte("key"), int64(1), []interface{}{RESPSimple("nested")}},
+	}
+	p := NewRESPProto()
+	var stream []byte
+	for _, v := range values {
+		var err error
+		if stream, err = p.SerializeTo(stream, v); err != nil {
+			t.Fatalf("serialize %v failed, err = %s", v, err)
+		}
+	}
+	reader := bufio.NewReaderSize(iotest.OneByteReader(bytes.NewReader(stream)), 16)
+	var got []interface{}
+	for len(got) < len(values) {
+		msgs, err := p.Decode(reader, 1024)
+		if err != nil {
+			t.Fatalf("decode failed, err = %s", err)
+		}
+		got = append(got, msgs...)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Fatalf("values not match, got = %#v", got)
+	}
+}
+
+func TestRESPDecode(t *testing.T) {
+	decode := func(s string) ([]interface{}, error) {
+		return NewRESPProto().Decode(bufio.NewReader(strings.NewReader(s)), 16)
+	}
+	// 流水线请求一次返回，空行不产生消息
+	msgs, err := decode("*1\r\n$4\r\nPING\r\n\r\nECHO  hi\n*1\r\n$4\r\nPING\r\n")
+	if err != nil {
+		t.Fatalf("decode failed, err = %s", err)
+	}
+	ping := []interface{}{[]byte("PING")}
+	expect := []interface{}{ping, []interface{}{[]byte("ECHO"), []byte("hi")}, ping}
+	if !reflect.DeepEqual(msgs, expect) {
+		t.Fatalf("messages not match, got = %#v", msgs)
+	}
+
+	if _, err = decode("$abc\r\n"); !errors.Is(err, ErrBadHeader) {
+		t.Fatalf("expect ErrBadHeader, err = %v", err)
+	}
+	if _, err = decode("$3\r\nabcd\r\n"); !errors.Is(err, ErrBadHeader) {
+		t.Fatalf("expect ErrBadHeader, err = %v", err)
+	}
+	if _, err = decode("$17\r\n"); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expect ErrFrameTooLarge, err = %v", err)
+	}
+	if _, err = decode(strings.Repeat("x", 32) + "\r\n"); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expect ErrFrameTooLarge, err = %v", err)
+	}
+	if _, err = decode(strings.Repeat("*1\r\n", maxRESPDepth+1)); !errors.Is(err, ErrBadHeader) {
+		t.Fatalf("expect ErrBadHeader, err = %v", err)
+	}
+	// 出错前解码的消息仍然返回
+	msgs, err = decode("+OK\r\n:x\r\n")
+	if !errors.Is(err, ErrBadHeader) || len(msgs) != 1 {
+		t.Fatalf("expect 1 message and ErrBadHeader, got = %v, err = %v", msgs, err)
+	}
+}
+
+// respServer PING回复PONG，其他命令原样返回
+type respServer struct {
+	NopHandler
+}
+
+func (h *respServer) OnData(conn *Connection, data interface{}) {
+	cmd := data.([]interface{})
+	if strings.EqualFold(string(cmd[0].([]byte)), "PING") {
+		conn.Net().SendData(conn, RESPSimple("PONG"))
+		return
+	}
+	conn.Net().SendData(conn, cmd)
+}
+
+func TestRESPServer(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.ListenWithHandler("127.0.0.1:0", NewRESPProto(), &respServer{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	sock, err := net.Dial("tcp", listen.LocalAddress())
+	if err != nil {
+		t.Fatalf("dial failed, err = %s", err)
+	}
+	defer sock.Close()
+
+	sock.SetReadDeadline(time.Now().Add(time.Second * 5))
+	sock.Write([]byte("PING\r\n*2\r\n$4\r\nECHO\r\n$5\r\nhello\r\n"))
+	expect := "+PONG\r\n*2\r\n$4\r\nECHO\r\n$5\r\nhello\r\n"
+	got := make([]byte, len(expect))
+	if _, err = io.ReadFull(sock, got); err != nil {
+		t.Fatalf("read reply failed, err = %s", err)
+	}
+	if string(got) != expect {
+		t.Fatalf("reply not match, got = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+func (n *SimpleNet) isClosing() bool {
+	return atomic.LoadInt32(&n.closing) != 0
+}
+
+// Shutdown 优雅关闭：停止accept和读取，在ctx结束前发送完队列中的数据，
+// 通知连接关闭事件，等待所有协程退出后释放资源。ctx结束时强制关闭未发送完的连接并返回ctx.Err()
+// 可以在Handler回调中调用，此时不等待回调工作池退出，其余worker在Shutdown返回后执行完队列中的回调；
+// 关闭事件派发到当前worker的队列，队列满时会阻塞到ctx结束，回调中调用时ctx应设置超时
+func (n *SimpleNet) Shutdown(ctx context.Context) error {
+	// 设置closing后不会再有新的连接、监听登记
+	n.lock.Lock()
+	if !atomic.CompareAndSwapInt32(&n.closing, 0, 1) {
+		n.lock.Unlock()
+		return ErrShutdown
+	}
+	listens := make([]*Listener, 0, len(n.listens))
+	for _, l := range n.listens {
+		listens = append(listens, l)
+	}
+	conns := make([]*Connection, 0, len(n.conns))
+	for _, conn := range n.conns {
+		conns = append(conns, conn)
+	}
+	n.lock.Unlock()
+
+	// 停止accept，udp的socket还要用来回包，最后再关闭
+	for _, l := range listens {
+		if l.packet != nil {
+			if l.casStatus(StatusListenning, StatusBroken) {
+				close(l.closed)
+				l.packet.SetReadDeadline(time.Now())
+			}
+		} else {
+			n.stopListen(l)
+		}
+	}
+	n.acceptWg.Wait()
+
+	// 停止读取，通知写协程发送完剩余数据后退出
+	var flush, broken []*Connection
+	for _, conn := range conns {
+		switch {
+		case conn.casStatus(StatusConnected, StatusBroken):
+			conn.lock.Lock()
+			conn.conn.SetReadDeadline(time.Now())
+			close(conn.flush)
+			conn.lock.Unlock()
+			flush = append(flush, conn)
+		case conn.casStatus(StatusReconnecting, StatusBroken):
+			// socket已经关闭，结束重连
+			conn.lock.Lock()
+			close(conn.closed)
+			conn.lock.Unlock()
+			broken = append(broken, conn)
+		}
+	}
+
+	var err error
+	flushed := make(chan struct{})
+	go func() {
+		n.writers.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		err = ctx.Err()
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("shutdown before flushed, err = %s\n", err))
+	}
+
+	// 关闭socket，通知关闭事件
+	for _, conn := range flush {
+		conn.lock.Lock()
+		close(conn.sockDone)
+		close(conn.closed)
+		conn.conn.Close()
+		conn.lock.Unlock()
+	}
+	for _, conn := range append(flush, broken...) {
+		n.unregister(conn)
+		n.notifyClosed(ctx, conn, EventConnectionClosed, ErrShutdown)
+	}
+	for _, l := range listens {
+		if l.packet != nil {
+			l.packet.Close()
+			n.unregisterListen(l)
+		}
+	}
+
+	// 事件队列可能已满且没有人取，阻塞在派发的读取、空闲检查协程丢弃事件后退出
+	close(n.done)
+	n.wg.Wait()
+	n.stopWorkers()
+	close(n.events)
+
+	return err
+}
@@ -0,0 +1,217 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownFlush(t *testing.T) {
+	sn := NewSimpleNet(nil)
+	defer SimpleNetDestroy(sn)
+
+	server := newTestHandler()
+	listen, err := sn.ListenWithHandler("127.0.0.1:0", &lenProto{}, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	n := NewSimpleNet(nil)
+	client := newTestHandler()
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, client)
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	count := 500
+	for i := 0; i < count; i++ {
+		if err = n.SendData(conn, []byte("queued before shutdown")); err != nil {
+			t.Fatalf("send failed, err = %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err = n.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed, err = %s", err)
+	}
+	if err = n.SendData(conn, []byte("after shutdown")); err == nil {
+		t.Fatalf("send after shutdown should fail")
+	}
+	if _, err = n.Connect(listen.LocalAddress(), nil); err != ErrShutdown {
+		t.Fatalf("connect after shutdown, err = %v", err)
+	}
+	if err = n.Shutdown(ctx); err != ErrShutdown {
+		t.Fatalf("shutdown twice, err = %v", err)
+	}
+
+	// 关闭前排队的数据全部送达
+	for i := 0; i < count; i++ {
+		select {
+		case <-server.data:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("only %d of %d messages flushed", i, count)
+		}
+	}
+	select {
+	case err = <-client.close:
+		if err != ErrShutdown {
+			t.Fatalf("final close event err = %v", err)
+		}
+	default:
+		t.Fatalf("final close event not delivered")
+	}
+}
+
+func TestShutdownPollEvent(t *testing.T) {
+	n := NewSimpleNet(nil)
+	listen, err := n.Listen("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	if _, err = n.Connect(listen.LocalAddress(), nil); err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	evt, err := n.PollEvent(5000)
+	if err != nil || evt.EventType != EventNewConnection {
+		t.Fatalf("expect new connection event, err = %v", err)
+	}
+
+	if err = n.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed, err = %s", err)
+	}
+	closed := 0
+	for {
+		evt, err = n.PollEvent(5000)
+		if err != nil {
+			break
+		}
+		if evt.EventType == EventConnectionClosed {
+			closed++
+		}
+	}
+	if closed != 2 {
+		t.Fatalf("expect 2 close events, got %d", closed)
+	}
+}
+
+func TestShutdownFullEventQueue(t *testing.T) {
+	n := NewSimpleNet(nil)
+	listen, err := n.Listen("127.0.0.1:0", &lenProto{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	cn := NewSimpleNet(nil)
+	defer SimpleNetDestroy(cn)
+	conn, err := cn.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, newTestHandler())
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	for i := 0; i < 3000; i++ {
+		if err = cn.SendData(conn, []byte("nobody polls")); err != nil {
+			t.Fatalf("send failed, err = %s", err)
+		}
+	}
+	// 不PollEvent，等待读取协程阻塞在已满的事件队列上
+	deadline := time.Now().Add(time.Second * 5)
+	for len(n.events) < cap(n.events) {
+		if time.Now().After(deadline) {
+			t.Fatalf("event queue not full, len = %d", len(n.events))
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		defer cancel()
+		done <- n.Shutdown(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatalf("shutdown blocked by full event queue")
+	}
+}
+
+type destroyHandler struct {
+	NopHandler
+	done chan struct{}
+}
+
+func (h *destroyHandler) OnData(conn *Connection, data interface{}) {
+	SimpleNetDestroy(conn.Net())
+	close(h.done)
+}
+
+func TestShutdownInHandler(t *testing.T) {
+	n := NewSimpleNet(nil)
+	h := &destroyHandler{done: make(chan struct{})}
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, h)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	cn := NewSimpleNet(nil)
+	defer SimpleNetDestroy(cn)
+	conn, err := cn.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, newTestHandler())
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	if err = cn.SendData(conn, []byte("quit")); err != nil {
+		t.Fatalf("send failed, err = %s", err)
+	}
+	select {
+	case <-h.done:
+	case <-time.After(time.Second * 3):
+		t.Fatalf("destroy in handler deadlocked")
+	}
+	if err = n.Shutdown(context.Background()); err != ErrShutdown {
+		t.Fatalf("shutdown after destroy, err = %v", err)
+	}
+}
+
+func TestDestroyDeliversClose(t *testing.T) {
+	sn := NewSimpleNet(nil)
+	defer SimpleNetDestroy(sn)
+	listen, err := sn.ListenWithHandler("127.0.0.1:0", &lenProto{}, &NopHandler{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	// ctx已取消时关闭事件也要送达，多次运行避免select随机选中ctx
+	for i := 0; i < 50; i++ {
+		n := NewSimpleNet(nil)
+		client := newTestHandler()
+		if _, err = n.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, client); err != nil {
+			t.Fatalf("connect failed, err = %s", err)
+		}
+		polled, err := n.Connect(listen.LocalAddress(), &lenProto{})
+		if err != nil {
+			t.Fatalf("connect failed, err = %s", err)
+		}
+		SimpleNetDestroy(n)
+
+		select {
+		case err = <-client.close:
+			if err != ErrShutdown {
+				t.Fatalf("close event err = %v", err)
+			}
+		default:
+			t.Fatalf("run %d: close event not delivered to handler", i)
+		}
+		closed := false
+		for {
+			evt, err := n.PollEvent(0)
+			if err != nil {
+				break
+			}
+			if evt.EventType == EventConnectionClosed && evt.Conn == polled {
+				closed = true
+			}
+		}
+		if !closed {
+			t.Fatalf("run %d: close event not delivered to PollEvent", i)
+		}
+	}
+}
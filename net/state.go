@@ -0,0 +1,136 @@
+package net
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// 连接状态迁移：
+//   StatusConnected    -> StatusReconnecting  startReconnect，持有conn.lock
+//   StatusReconnecting -> StatusConnected     reconnect成功，持有conn.lock
+//   StatusConnected    -> StatusBroken        breakConn、Shutdown
+//   StatusReconnecting -> StatusBroken        breakConn、Shutdown
+// 迁移均通过CAS完成，并发关闭时只有一方成功并负责释放资源。
+// conn.lock只保护socket相关字段(conn、sockDone、地址)的替换。
+
+func (c *Connection) casStatus(from, to int64) bool {
+	return atomic.CompareAndSwapInt64(&c.status, from, to)
+}
+
+func (l *Listener) casStatus(from, to int64) bool {
+	return atomic.CompareAndSwapInt64(&l.status, from, to)
+}
+
+// reserveIO 预留读写、空闲检查协程计数，必须在Shutdown等待之前完成
+func (n *SimpleNet) reserveIO() {
+	n.wg.Add(3)
+	n.writers.Add(1)
+}
+
+// register 登记连接并预留读写协程，SimpleNet或所属Listener已经关闭时返回false
+func (n *SimpleNet) register(conn *Connection) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.isClosing() {
+		return false
+	}
+	if l := conn.listen; l != nil {
+		l.lock.Lock()
+		if l.Status() != StatusListenning {
+			l.lock.Unlock()
+			return false
+		}
+		l.conns[conn.id] = conn
+		l.lock.Unlock()
+	}
+	n.conns[conn.id] = conn
+	n.reserveIO()
+	conn.connected()
+
+	return true
+}
+
+func (n *SimpleNet) unregister(conn *Connection) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	delete(n.conns, conn.id)
+	if l := conn.listen; l != nil {
+		l.lock.Lock()
+		delete(l.conns, conn.id)
+		l.lock.Unlock()
+	}
+}
+
+// registerListen 登记监听并预留accept协程，SimpleNet已经关闭时返回false
+func (n *SimpleNet) registerListen(l *Listener) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.isClosing() {
+		return false
+	}
+	n.listens[l.id] = l
+	n.acceptWg.Add(1)
+
+	return true
+}
+
+func (n *SimpleNet) unregisterListen(l *Listener) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	delete(n.listens, l.id)
+}
+
+// breakConn 迁移到StatusBroken并释放socket，并发调用时只有一个返回true
+func (n *SimpleNet) breakConn(conn *Connection) bool {
+	for {
+		status := conn.Status()
+		if status != StatusConnected && status != StatusReconnecting {
+			return false
+		}
+		if !conn.casStatus(status, StatusBroken) {
+			continue
+		}
+		conn.lock.Lock()
+		if status == StatusConnected {
+			// 重连中的sockDone已经关闭
+			close(conn.sockDone)
+		}
+		close(conn.closed)
+		conn.conn.Close()
+		conn.lock.Unlock()
+
+		n.unregister(conn)
+		return true
+	}
+}
+
+// notifyClosed 每个连接只通知一次关闭或错误事件
+func (n *SimpleNet) notifyClosed(ctx context.Context, conn *Connection, evt int, err error) {
+	if !atomic.CompareAndSwapInt32(&conn.notified, 0, 1) {
+		return
+	}
+	n.emitContext(ctx, &ConnEvent{
+		EventType: evt,
+		Conn:      conn,
+		Data:      err,
+	})
+}
+
+// stopListen 只停止accept，不关闭已有连接，并发调用时只有一个返回true
+func (n *SimpleNet) stopListen(listen *Listener) bool {
+	if !listen.casStatus(StatusListenning, StatusBroken) {
+		return false
+	}
+	close(listen.closed)
+	if listen.packet != nil {
+		listen.packet.Close()
+	} else {
+		listen.listen.Close()
+	}
+	n.unregisterListen(listen)
+	return true
+}
@@ -0,0 +1,206 @@
+package net
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 以下用例用于 go test -race
+
+func waitConnCount(t *testing.T, n *SimpleNet, count int) {
+	deadline := time.Now().Add(time.Second * 10)
+	for n.ConnectionCount() != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection count = %d, expect %d", n.ConnectionCount(), count)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// closeHandler 收到数据后由服务端关闭连接，和客户端的关闭并发
+type closeHandler struct {
+	NopHandler
+	lock   sync.Mutex
+	closed map[int64]int
+}
+
+func (h *closeHandler) OnData(conn *Connection, data interface{}) {
+	conn.Net().CloseConn(conn)
+}
+func (h *closeHandler) OnClose(conn *Connection, err error) {
+	h.count(conn)
+}
+func (h *closeHandler) OnError(conn *Connection, err error) {
+	h.count(conn)
+}
+func (h *closeHandler) count(conn *Connection) {
+	h.lock.Lock()
+	h.closed[conn.ID()]++
+	h.lock.Unlock()
+}
+
+func TestConcurrentConnectClose(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := &closeHandler{closed: make(map[int64]int)}
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	addr := listen.LocalAddress()
+
+	const workers, rounds = 32, 64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				conn, err := n.ConnectWithHandler(addr, &lenProto{}, &NopHandler{})
+				if err != nil {
+					t.Errorf("connect failed, err = %s", err)
+					return
+				}
+				var close sync.WaitGroup
+				close.Add(3)
+				go func() {
+					defer close.Done()
+					for k := 0; k < 4; k++ {
+						err := n.SendData(conn, []byte("data"))
+						if err != nil && err != ErrNotConnected {
+							t.Errorf("send failed, err = %s", err)
+						}
+					}
+				}()
+				for k := 0; k < 2; k++ {
+					go func() {
+						defer close.Done()
+						n.CloseConn(conn)
+					}()
+				}
+				close.Wait()
+				if conn.Status() != StatusBroken {
+					t.Errorf("connection status = %d, expect broken", conn.Status())
+				}
+				if err = n.SendData(conn, []byte("data")); err != ErrNotConnected {
+					t.Errorf("send after close, err = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitConnCount(t, n, 0)
+	if c := listen.ConnectionCount(); c != 0 {
+		t.Errorf("listener connection count = %d, expect 0", c)
+	}
+
+	// 每个服务端连接最多通知一次关闭
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	for id, count := range server.closed {
+		if count != 1 {
+			t.Fatalf("connection %d notified %d times", id, count)
+		}
+	}
+}
+
+func TestCloseListenWhileAccepting(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	listen, err := n.ListenWithHandler("127.0.0.1:0", nil, &NopHandler{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	addr := listen.LocalAddress()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 32; j++ {
+				sock, err := net.Dial("tcp", addr)
+				if err != nil {
+					// 监听已经关闭
+					return
+				}
+				defer sock.Close()
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond * 20)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.CloseListen(listen)
+		}()
+	}
+	wg.Wait()
+
+	if listen.Status() != StatusBroken {
+		t.Fatalf("listener status = %d, expect broken", listen.Status())
+	}
+	// 关闭之后accept的连接不会留在注册表中
+	waitConnCount(t, n, 0)
+}
+
+func TestShutdownWhileBusy(t *testing.T) {
+	n := NewSimpleNet(nil)
+
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, &NopHandler{})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	addr := listen.LocalAddress()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				conn, err := n.ConnectWithHandler(addr, &lenProto{}, &NopHandler{})
+				if err != nil {
+					if err != ErrShutdown {
+						// Shutdown过程中服务端可能已经关闭
+						time.Sleep(time.Millisecond)
+					}
+					continue
+				}
+				for k := 0; k < 8; k++ {
+					n.SendData(conn, []byte("busy"))
+				}
+				n.CloseConn(conn)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond * 200)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err = n.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed, err = %s", err)
+	}
+	close(stop)
+	wg.Wait()
+
+	if n.ConnectionCount() != 0 {
+		t.Fatalf("connection count = %d after shutdown", n.ConnectionCount())
+	}
+	if _, err = n.Connect(addr, nil); err != ErrShutdown {
+		t.Fatalf("connect after shutdown, err = %v", err)
+	}
+}
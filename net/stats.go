@@ -0,0 +1,203 @@
+package net
+
+import (
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// counters 流量计数，原子读写。每个连接一份，同时累加到所属Listener或主动连接的汇总
+type counters struct {
+	bytesIn     int64
+	bytesOut    int64
+	framesIn    int64
+	framesOut   int64
+	protoErrors int64
+	connects    int64 // 连接成功次数，包括重连
+}
+
+// ConnStats 连接统计快照
+type ConnStats struct {
+	ID int64
+	// Listener 所属Listener，主动连接为0
+	Listener    int64
+	Network     string
+	LocalAddr   string
+	RemoteAddr  string
+	Status      int64
+	BytesIn     int64
+	BytesOut    int64
+	FramesIn    int64
+	FramesOut   int64
+	ProtoErrors int64
+	QueueLen    int
+	QueueBytes  int
+	ConnectTime time.Time
+	ReadTime    time.Time
+	WriteTime   time.Time
+}
+
+// ListenerStats Listener统计快照，计数包括已关闭的连接
+type ListenerStats struct {
+	ID          int64
+	Network     string
+	Addr        string
+	Connections int
+	Connects    int64
+	BytesIn     int64
+	BytesOut    int64
+	FramesIn    int64
+	FramesOut   int64
+	ProtoErrors int64
+	// QueueLen、QueueBytes 当前连接发送队列的消息数、字节数之和
+	QueueLen   int
+	QueueBytes int
+}
+
+// Stats SimpleNet统计快照
+type Stats struct {
+	Time      time.Time
+	Listeners []ListenerStats
+	// Outbound 主动连接的汇总，ID为0
+	Outbound    ListenerStats
+	Connections []ConnStats
+}
+
+func (c *Connection) total() *counters {
+	if c.listen != nil {
+		return &c.listen.stat
+	}
+	return &c.net.outbound
+}
+
+func (c *Connection) connected() {
+	atomic.StoreInt64(&c.connTime, time.Now().UnixNano())
+	atomic.AddInt64(&c.stat.connects, 1)
+	atomic.AddInt64(&c.total().connects, 1)
+}
+
+func (c *Connection) addBytesIn(n int64) {
+	atomic.AddInt64(&c.stat.bytesIn, n)
+	atomic.AddInt64(&c.total().bytesIn, n)
+}
+
+func (c *Connection) addBytesOut(n int64) {
+	atomic.AddInt64(&c.stat.bytesOut, n)
+	atomic.AddInt64(&c.total().bytesOut, n)
+}
+
+func (c *Connection) addFrameIn() {
+	atomic.AddInt64(&c.stat.framesIn, 1)
+	atomic.AddInt64(&c.total().framesIn, 1)
+}
+
+func (c *Connection) addFramesOut(n int) {
+	atomic.AddInt64(&c.stat.framesOut, (int64)(n))
+	atomic.AddInt64(&c.total().framesOut, (int64)(n))
+}
+
+func (c *Connection) addProtoError() int64 {
+	atomic.AddInt64(&c.total().protoErrors, 1)
+	return atomic.AddInt64(&c.stat.protoErrors, 1)
+}
+
+// countReader 统计从socket读到的字节数
+type countReader struct {
+	conn *Connection
+	r    io.Reader
+}
+
+func (r *countReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.conn.addBytesIn((int64)(n))
+	}
+	return n, err
+}
+
+// ConnectTime 连接成功的时间，重连后为重连成功的时间
+func (c *Connection) ConnectTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.connTime))
+}
+
+// Stats 连接统计
+func (c *Connection) Stats() ConnStats {
+	s := ConnStats{
+		ID:          c.id,
+		Network:     c.network,
+		LocalAddr:   c.LocalAddress(),
+		RemoteAddr:  c.RemoteAddress(),
+		Status:      c.Status(),
+		BytesIn:     atomic.LoadInt64(&c.stat.bytesIn),
+		BytesOut:    atomic.LoadInt64(&c.stat.bytesOut),
+		FramesIn:    atomic.LoadInt64(&c.stat.framesIn),
+		FramesOut:   atomic.LoadInt64(&c.stat.framesOut),
+		ProtoErrors: atomic.LoadInt64(&c.stat.protoErrors),
+		ConnectTime: c.ConnectTime(),
+		ReadTime:    c.ReadTime(),
+		WriteTime:   c.WriteTime(),
+	}
+	s.QueueLen, s.QueueBytes = c.QueueLen()
+	if c.listen != nil {
+		s.Listener = c.listen.id
+	}
+	return s
+}
+
+func (s *ListenerStats) load(c *counters) {
+	s.Connects = atomic.LoadInt64(&c.connects)
+	s.BytesIn = atomic.LoadInt64(&c.bytesIn)
+	s.BytesOut = atomic.LoadInt64(&c.bytesOut)
+	s.FramesIn = atomic.LoadInt64(&c.framesIn)
+	s.FramesOut = atomic.LoadInt64(&c.framesOut)
+	s.ProtoErrors = atomic.LoadInt64(&c.protoErrors)
+}
+
+// Stats Listener统计
+func (l *Listener) Stats() ListenerStats {
+	s := ListenerStats{
+		ID:      l.id,
+		Network: l.network,
+		Addr:    l.LocalAddress(),
+	}
+	s.load(&l.stat)
+	for _, conn := range l.Connections() {
+		msgs, bytes := conn.QueueLen()
+		s.Connections++
+		s.QueueLen += msgs
+		s.QueueBytes += bytes
+	}
+	return s
+}
+
+// Stats 所有Listener和连接的统计，Listeners、Connections按ID排序
+func (n *SimpleNet) Stats() *Stats {
+	s := &Stats{
+		Time: time.Now(),
+	}
+	n.lock.RLock()
+	listens := make([]*Listener, 0, len(n.listens))
+	for _, l := range n.listens {
+		listens = append(listens, l)
+	}
+	n.lock.RUnlock()
+	sort.Slice(listens, func(i, j int) bool {
+		return listens[i].id < listens[j].id
+	})
+	for _, l := range listens {
+		s.Listeners = append(s.Listeners, l.Stats())
+	}
+
+	s.Outbound.load(&n.outbound)
+	for _, conn := range n.Connections() {
+		cs := conn.Stats()
+		if cs.Listener == 0 {
+			s.Outbound.Connections++
+			s.Outbound.QueueLen += cs.QueueLen
+			s.Outbound.QueueBytes += cs.QueueBytes
+		}
+		s.Connections = append(s.Connections, cs)
+	}
+	return s
+}
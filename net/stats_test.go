@@ -0,0 +1,98 @@
+package net
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitStats 计数在写完成后才累加，轮询等待
+func waitStats(t *testing.T, desc string, cond func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("wait %s timeout", desc)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestStats(t *testing.T) {
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenWithHandler("127.0.0.1:0", &lenProto{}, server)
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+	conn, err := n.ConnectWithHandler(listen.LocalAddress(), &lenProto{}, &NopHandler{})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	const count = 10
+	for i := 0; i < count; i++ {
+		if err = n.SendData(conn, []byte("hello")); err != nil {
+			t.Fatalf("send failed, err = %s", err)
+		}
+	}
+	for i := 0; i < count; i++ {
+		recvString(t, server.data, 5)
+	}
+	// 4字节长度 + 5字节包体
+	const bytes = count * 9
+	waitStats(t, "client stats", func() bool {
+		s := conn.Stats()
+		return s.FramesOut == count && s.BytesOut == bytes
+	})
+
+	stats := n.Stats()
+	if len(stats.Listeners) != 1 || len(stats.Connections) != 2 {
+		t.Fatalf("stats not match, listeners = %d, connections = %d",
+			len(stats.Listeners), len(stats.Connections))
+	}
+	ls := stats.Listeners[0]
+	if ls.ID != listen.ID() || ls.Connections != 1 || ls.Connects != 1 ||
+		ls.FramesIn != count || ls.BytesIn != bytes {
+		t.Fatalf("listener stats not match, got = %+v", ls)
+	}
+	out := stats.Outbound
+	if out.Connections != 1 || out.Connects != 1 || out.FramesOut != count || out.BytesOut != bytes {
+		t.Fatalf("outbound stats not match, got = %+v", out)
+	}
+	for _, cs := range stats.Connections {
+		listener := listen.ID()
+		if cs.ID == conn.ID() {
+			listener = 0
+		}
+		if cs.Listener != listener || cs.ConnectTime.IsZero() {
+			t.Fatalf("connection stats not match, got = %+v", cs)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	n.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE simplenet_frames_in_total counter",
+		fmt.Sprintf("simplenet_frames_in_total{listener=\"%d\",network=\"tcp\",addr=\"%s\"} %d",
+			listen.ID(), listen.LocalAddress(), count),
+		fmt.Sprintf("simplenet_bytes_out_total{listener=\"outbound\",network=\"\",addr=\"\"} %d", bytes),
+		"simplenet_connections{listener=\"outbound\",network=\"\",addr=\"\"} 1",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("metrics missing %q, got:\n%s", line, body)
+		}
+	}
+
+	// 连接关闭后Listener的累计计数不变
+	n.CloseConn(conn)
+	waitStats(t, "connection closed", func() bool {
+		return listen.Stats().Connections == 0
+	})
+	if ls = listen.Stats(); ls.Connects != 1 || ls.BytesIn != bytes {
+		t.Fatalf("listener stats changed after close, got = %+v", ls)
+	}
+}
@@ -0,0 +1,38 @@
+package net
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// IDecoder proto可选实现，头部不定长的协议(HTTP/1.1、RESP、文本行等)自行从reader解码。
+// 实现IDecoder时不使用HeadLen、BodyLen和Parse
+type IDecoder interface {
+	// Decode 从r读取，返回解码出的0个或多个消息，单个消息不能超过maxFrame，
+	// 没有消息时也必须消费数据。返回错误时先投递已解码的消息再断开连接，
+	// 错误包装ErrBadHeader或ErrFrameTooLarge时投递EventProtoError
+	Decode(r *bufio.Reader, maxFrame uint32) ([]interface{}, error)
+}
+
+// StreamBase 实现IDecoder的proto嵌入后只需实现Decode和Serialize
+type StreamBase struct{}
+
+// FilterAccept IProto
+func (StreamBase) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+// HeadLen IProto，不使用
+func (StreamBase) HeadLen() uint32 {
+	return 0
+}
+
+// BodyLen IProto，不使用
+func (StreamBase) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("stream proto is read by Decode")
+}
+
+// Parse IProto，不使用
+func (StreamBase) Parse(head interface{}, body []byte) (interface{}, error) {
+	return nil, fmt.Errorf("stream proto is read by Decode")
+}
@@ -0,0 +1,76 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+func (o *Options) clientHandshake(ctx context.Context, sock net.Conn, addr string) (net.Conn, error) {
+	config := o.TLSConfig
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		// 没有指定ServerName时使用连接地址校验证书
+		config = config.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			config.ServerName = host
+		}
+	}
+	tlsconn := tls.Client(sock, config)
+	if err := o.handshake(ctx, tlsconn); err != nil {
+		return nil, err
+	}
+	return tlsconn, nil
+}
+
+func (o *Options) serverHandshake(sock net.Conn) (net.Conn, error) {
+	tlsconn := tls.Server(sock, o.TLSConfig)
+	if err := o.handshake(context.Background(), tlsconn); err != nil {
+		return nil, err
+	}
+	return tlsconn, nil
+}
+
+func (o *Options) handshake(ctx context.Context, tlsconn *tls.Conn) error {
+	timeout := o.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = defHandshakeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return tlsconn.HandshakeContext(ctx)
+}
+
+// TLSState tls连接状态，非tls连接返回nil
+func (c *Connection) TLSState() *tls.ConnectionState {
+	c.lock.Lock()
+	sock := c.conn
+	c.lock.Unlock()
+
+	tlsconn, ok := sock.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tlsconn.ConnectionState()
+	return &state
+}
+
+// PeerCertificates 对端证书，可在IProto.FilterAccept中做授权判断
+func (c *Connection) PeerCertificates() []*x509.Certificate {
+	state := c.TLSState()
+	if state == nil {
+		return nil
+	}
+	return state.PeerCertificates
+}
+
+// ListenTLS 以tls监听网络
+func (n *SimpleNet) ListenTLS(addr string, proto IProto, config *tls.Config) (*Listener, error) {
+	return n.ListenContext(context.Background(), addr, proto, &Options{TLSConfig: config})
+}
+
+// ConnectTLS 以tls连接服务器
+func (n *SimpleNet) ConnectTLS(addr string, proto IProto, config *tls.Config) (*Connection, error) {
+	return n.ConnectContext(context.Background(), addr, proto, &Options{TLSConfig: config})
+}
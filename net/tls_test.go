@@ -0,0 +1,166 @@
+package net
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key failed, err = %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create ca cert failed, err = %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, name string, serial int64) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed, err = %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create cert failed, err = %s", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// authProto 只接受指定CommonName的客户端证书
+type authProto struct {
+	lenProto
+	allow string
+}
+
+func (p *authProto) FilterAccept(conn *Connection) bool {
+	certs := conn.PeerCertificates()
+	return len(certs) > 0 && certs[0].Subject.CommonName == p.allow
+}
+
+func TestTLSMutualAuth(t *testing.T) {
+	ca := newTestCA(t)
+	serverConf := &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", 2)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+
+	n := NewSimpleNet(nil)
+	defer SimpleNetDestroy(n)
+
+	server := newTestHandler()
+	listen, err := n.ListenContext(context.Background(), "127.0.0.1:0",
+		&authProto{allow: "allowed"}, &Options{Handler: server, TLSConfig: serverConf})
+	if err != nil {
+		t.Fatalf("listen failed, err = %s", err)
+	}
+
+	client := newTestHandler()
+	conn, err := n.ConnectContext(context.Background(), listen.LocalAddress(), &lenProto{},
+		&Options{
+			Handler: client,
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{ca.issue(t, "allowed", 3)},
+				RootCAs:      ca.pool,
+			},
+		})
+	if err != nil {
+		t.Fatalf("connect failed, err = %s", err)
+	}
+	if state := conn.TLSState(); state == nil || !state.HandshakeComplete {
+		t.Fatalf("tls handshake not complete")
+	}
+	if certs := conn.PeerCertificates(); len(certs) == 0 || certs[0].Subject.CommonName != "server" {
+		t.Fatalf("server certificate not exposed")
+	}
+
+	var peer *Connection
+	select {
+	case peer = <-server.connect:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("server OnConnect timeout")
+	}
+	if certs := peer.PeerCertificates(); len(certs) == 0 || certs[0].Subject.CommonName != "allowed" {
+		t.Fatalf("client certificate not exposed")
+	}
+
+	if err = n.SendData(conn, []byte("over tls")); err != nil {
+		t.Fatalf("send failed, err = %s", err)
+	}
+	select {
+	case b := <-server.data:
+		if string(b) != "over tls" {
+			t.Fatalf("data not match, got = %s", b)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("receive timeout")
+	}
+
+	// 证书合法但未授权的客户端被FilterAccept拒绝
+	denied, err := n.ConnectContext(context.Background(), listen.LocalAddress(), &lenProto{},
+		&Options{
+			Handler: newTestHandler(),
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{ca.issue(t, "denied", 4)},
+				RootCAs:      ca.pool,
+			},
+		})
+	if err == nil {
+		n.SendData(denied, []byte("denied"))
+		select {
+		case <-server.connect:
+			t.Fatalf("unauthorized client should be rejected")
+		case <-time.After(time.Millisecond * 200):
+		}
+	}
+
+	// 没有客户端证书握手失败
+	_, err = n.ConnectTLS(listen.LocalAddress(), &lenProto{}, &tls.Config{RootCAs: ca.pool})
+	if err == nil {
+		select {
+		case <-server.connect:
+			t.Fatalf("client without certificate should be rejected")
+		case <-time.After(time.Millisecond * 200):
+		}
+	}
+}
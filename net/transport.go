@@ -0,0 +1,240 @@
+package net
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const (
+	defPacketBufferSize = 65536
+	defPacketQueueSize  = 256
+)
+
+// parseAddress 解析网络类型，支持tcp://、unix://、udp://等前缀，没有前缀时使用network，默认tcp
+func parseAddress(network string, addr string) (string, string, error) {
+	if index := strings.Index(addr, "://"); index >= 0 {
+		network, addr = addr[:index], addr[index+3:]
+	}
+	if network == "" {
+		network = "tcp"
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix", "udp", "udp4", "udp6":
+	default:
+		return "", "", fmt.Errorf("unsupported network %s", network)
+	}
+	return network, addr, nil
+}
+
+func isPacketNetwork(network string) bool {
+	return strings.HasPrefix(network, "udp")
+}
+
+func resolveAddr(network string, addr string) (net.Addr, error) {
+	switch {
+	case network == "unix":
+		return net.ResolveUnixAddr(network, addr)
+	case isPacketNetwork(network):
+		return net.ResolveUDPAddr(network, addr)
+	}
+	return net.ResolveTCPAddr(network, addr)
+}
+
+// Network 连接的网络类型
+func (c *Connection) Network() string {
+	return c.network
+}
+
+// Network 监听的网络类型
+func (l *Listener) Network() string {
+	return l.network
+}
+
+func (c *Connection) readBufferSize() int {
+	if c.opt.ReadBufferSize <= 0 && isPacketNetwork(c.network) {
+		// 数据报不能被截断
+		return defPacketBufferSize
+	}
+	return c.opt.readBufferSize()
+}
+
+// writeBatchSize 数据报合并后会变成一个包，不能合并
+func (c *Connection) writeBatchSize() int {
+	if c.opt.WriteBatchSize < 0 || isPacketNetwork(c.network) {
+		return 0
+	}
+	if c.opt.WriteBatchSize == 0 {
+		return defWriteBatchSize
+	}
+	return c.opt.WriteBatchSize
+}
+
+// udpConn 以对端地址区分的udp伪连接，把数据报转成流供读协程使用
+type udpConn struct {
+	packet net.PacketConn
+	remote net.Addr
+
+	in       chan []byte
+	buf      []byte
+	deadline int64
+	idle     time.Duration // 等待数据报超过该时间返回ErrIdleTimeout，0不检查
+	closed   chan struct{}
+	once     sync.Once
+	onClose  func()
+}
+
+func newUDPConn(packet net.PacketConn, remote net.Addr, idle time.Duration, onClose func()) *udpConn {
+	return &udpConn{
+		packet:  packet,
+		remote:  remote,
+		idle:    idle,
+		in:      make(chan []byte, defPacketQueueSize),
+		closed:  make(chan struct{}),
+		onClose: onClose,
+	}
+}
+
+func (c *udpConn) push(data []byte) bool {
+	select {
+	case c.in <- data:
+		return true
+	case <-c.closed:
+		return false
+	default:
+		return false
+	}
+}
+
+func (c *udpConn) Read(b []byte) (int, error) {
+	if len(c.buf) == 0 {
+		var timeout <-chan time.Time
+		wait, idle := (time.Duration)(-1), false
+		if d := atomic.LoadInt64(&c.deadline); d > 0 {
+			wait = time.Until(time.Unix(0, d))
+		}
+		if c.idle > 0 && (wait < 0 || c.idle < wait) {
+			wait, idle = c.idle, true
+		}
+		if wait >= 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case data := <-c.in:
+			c.buf = data
+		case <-c.closed:
+			return 0, io.EOF
+		case <-timeout:
+			if idle {
+				return 0, ErrIdleTimeout
+			}
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+	count := copy(b, c.buf)
+	c.buf = c.buf[count:]
+	return count, nil
+}
+
+func (c *udpConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	return c.packet.WriteTo(b, c.remote)
+}
+
+func (c *udpConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return nil
+}
+
+func (c *udpConn) LocalAddr() net.Addr {
+	return c.packet.LocalAddr()
+}
+func (c *udpConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+func (c *udpConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+func (c *udpConn) SetReadDeadline(t time.Time) error {
+	d := (int64)(0)
+	if !t.IsZero() {
+		d = t.UnixNano()
+	}
+	atomic.StoreInt64(&c.deadline, d)
+	return nil
+}
+func (c *udpConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// packetListening udp监听，新的对端地址视为新连接，
+// 对端空闲超过Options.PeerIdle关闭，同时存在的对端数不超过Options.MaxPeers
+func (n *SimpleNet) packetListening(l *Listener) {
+	defer n.acceptWg.Done()
+	defer func() {
+		err := recover()
+		if err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("packet listenning panic: %s\n", err))
+		}
+	}()
+	buf := make([]byte, defPacketBufferSize)
+	for {
+		count, addr, err := l.packet.ReadFrom(buf)
+		if err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("read packet failed, err = %s\n", err))
+			if l.Status() != StatusListenning {
+				break
+			}
+			continue
+		}
+		data := make([]byte, count)
+		copy(data, buf[:count])
+
+		key := addr.String()
+		l.lock.Lock()
+		peer, ok := l.peers[key]
+		if !ok && l.opt.MaxPeers > 0 && len(l.peers) >= l.opt.MaxPeers {
+			l.lock.Unlock()
+			n.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("too many peers, drop packet, count = %d, remoteAddr = %s\n", count, key))
+			continue
+		}
+		if !ok {
+			peer = newUDPConn(l.packet, addr, l.opt.peerIdle(), func() {
+				l.lock.Lock()
+				delete(l.peers, key)
+				l.lock.Unlock()
+			})
+			l.peers[key] = peer
+		}
+		l.lock.Unlock()
+
+		if !ok {
+			n.accept(l, peer)
+		}
+		if !peer.push(data) {
+			n.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("drop packet, count = %d, remoteAddr = %s\n", count, key))
+		}
+	}
+}